@@ -0,0 +1,213 @@
+// Package client is a typed Go client for the API, for other services to use instead of building
+// requests by hand. Idempotent calls, and POSTs sent with an Idempotency-Key, are retried on network
+// errors and 429, 408 and 5xx responses, and error responses are returned as *Error.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/retry"
+)
+
+// Endpoints lists the routes the client calls, as ServeMux patterns. Tests check it against the
+// server's route table so that new routes aren't left without a client method.
+var Endpoints = []string{
+	"POST /v1/signup",
+	"GET /v1/users",
+	"GET /v1/users/{clerk_user_id}",
+	"DELETE /v1/users/{id}",
+	"GET /v1/me",
+}
+
+// idempotencyKeyInUseProblem is the problem type of the 409 for a repeat of a request which is
+// still running
+const idempotencyKeyInUseProblem = "/problems/idempotency-key-in-use"
+
+// TokenSource returns the bearer token sent with each request, e.g. a Clerk session token
+type TokenSource func(ctx context.Context) (string, error)
+
+// Client calls the API. It is safe for concurrent use.
+type Client struct {
+	baseURL     *url.URL
+	httpClient  *http.Client
+	tokenSource TokenSource
+	maxAttempts int
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithToken sends the same bearer token with every request
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.tokenSource = func(context.Context) (string, error) { return token, nil }
+	}
+}
+
+// WithTokenSource fetches the bearer token before each request, for tokens which expire
+func WithTokenSource(tokenSource TokenSource) Option {
+	return func(c *Client) {
+		c.tokenSource = tokenSource
+	}
+}
+
+// WithHTTPClient replaces the default HTTP client, which times out after 30 seconds
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithMaxAttempts sets how many times idempotent calls are attempted, 1 disables retries (3 by
+// default). New rejects values below 1. Retries also stop a minute after the first attempt.
+func WithMaxAttempts(maxAttempts int) Option {
+	return func(c *Client) {
+		c.maxAttempts = maxAttempts
+	}
+}
+
+// New creates a client for the API at baseURL, e.g. "https://api.example.com"
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q: must be absolute", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	c := &Client{
+		baseURL:     u,
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+		maxAttempts: 3,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.maxAttempts < 1 {
+		return nil, fmt.Errorf("invalid max attempts %d: must be at least 1", c.maxAttempts)
+	}
+	return c, nil
+}
+
+// do sends the request and decodes a JSON response into out, which may be nil. GET and DELETE are
+// idempotent, so they are retried. The response headers are returned for callers that need them.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) (http.Header, error) {
+	return c.send(ctx, method, path, "", body, out)
+}
+
+// doIdempotent sends a request which isn't idempotent by itself, such as a POST, with a new
+// Idempotency-Key. The server replays the first response for repeats of the key, so the request
+// is retried like GET and DELETE, and also while the first attempt is still running.
+func (c *Client) doIdempotent(ctx context.Context, method, path string, body, out any) (http.Header, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate idempotency key: %w", err)
+	}
+	return c.send(ctx, method, path, hex.EncodeToString(key), body, out)
+}
+
+func (c *Client) send(ctx context.Context, method, path, idempotencyKey string, body, out any) (http.Header, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("failed to encode request body: %w", err)
+		}
+	}
+
+	if method != http.MethodGet && method != http.MethodDelete && idempotencyKey == "" {
+		return c.attempt(ctx, method, path, idempotencyKey, payload, out)
+	}
+
+	policy := retry.DefaultPolicy()
+	policy.MaxAttempts = c.maxAttempts
+	policy.Retryable = retryable
+	if idempotencyKey != "" {
+		policy.Retryable = func(err error) bool {
+			var apiErr *Error
+			if errors.As(err, &apiErr) && apiErr.Type == idempotencyKeyInUseProblem {
+				return true
+			}
+			return retryable(err)
+		}
+	}
+
+	var header http.Header
+	err := retry.Do(ctx, policy, func(ctx context.Context) error {
+		var err error
+		header, err = c.attempt(ctx, method, path, idempotencyKey, payload, out)
+		return err
+	})
+	return header, err
+}
+
+func (c *Client) attempt(ctx context.Context, method, path, idempotencyKey string, payload []byte, out any) (http.Header, error) {
+	target, err := c.baseURL.Parse(c.baseURL.Path + path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request path %q: %w", path, err)
+	}
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	if c.tokenSource != nil {
+		token, err := c.tokenSource(ctx)
+		if err != nil {
+			return nil, retry.Permanent(fmt.Errorf("failed to get bearer token: %w", err))
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, decodeError(resp)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, retry.Permanent(fmt.Errorf("failed to decode %s %s response: %w", method, path, err))
+		}
+	}
+	return resp.Header, nil
+}
+
+// retryable retries network errors and responses which may succeed later
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return retry.HTTPStatusRetryable(apiErr.StatusCode)
+	}
+	return true
+}
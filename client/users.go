@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// User is a user of the API
+type User struct {
+	ID        int        `json:"id"`
+	ClerkID   string     `json:"clerk_id"`
+	Email     *string    `json:"email"`
+	FirstName *string    `json:"first_name"`
+	LastName  *string    `json:"last_name"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// SignUpRequest creates a user for a Clerk user
+type SignUpRequest struct {
+	ClerkID   string  `json:"clerk_id"`
+	Email     *string `json:"email,omitempty"`
+	FirstName *string `json:"first_name,omitempty"`
+	LastName  *string `json:"last_name,omitempty"`
+}
+
+// ListUsersOptions configures ListUsers
+type ListUsersOptions struct {
+	// PageSize is the number of users fetched per request, 50 when 0
+	PageSize int
+}
+
+// defaultPageSize is sent when ListUsersOptions.PageSize isn't set, as the server returns every
+// user at once unless a page size is given
+const defaultPageSize = 50
+
+// SignUp creates a user. It is sent with an Idempotency-Key so that it can be retried, a retry
+// after a lost response receives the first response. A conflict error means the user already exists.
+func (c *Client) SignUp(ctx context.Context, req SignUpRequest) error {
+	_, err := c.doIdempotent(ctx, http.MethodPost, "/v1/signup", req, nil)
+	return err
+}
+
+// ListUsers iterates over every user, fetching the next page as the previous one is consumed.
+// Iteration stops after the first error.
+//
+//	for user, err := range c.ListUsers(ctx, nil) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (c *Client) ListUsers(ctx context.Context, opts *ListUsersOptions) iter.Seq2[User, error] {
+	return func(yield func(User, error) bool) {
+		pageSize := defaultPageSize
+		if opts != nil && opts.PageSize > 0 {
+			pageSize = opts.PageSize
+		}
+		path := "/v1/users?" + url.Values{"limit": {strconv.Itoa(pageSize)}}.Encode()
+
+		for path != "" {
+			var users []User
+			header, err := c.do(ctx, http.MethodGet, path, nil, &users)
+			if err != nil {
+				yield(User{}, err)
+				return
+			}
+			for _, user := range users {
+				if !yield(user, nil) {
+					return
+				}
+			}
+			path = nextPage(header)
+		}
+	}
+}
+
+// GetUser returns the user with the Clerk user ID
+func (c *Client) GetUser(ctx context.Context, clerkUserID string) (User, error) {
+	var user User
+	_, err := c.do(ctx, http.MethodGet, "/v1/users/"+url.PathEscape(clerkUserID), nil, &user)
+	return user, err
+}
+
+// DeleteUser deletes the user with the ID
+func (c *Client) DeleteUser(ctx context.Context, id int) error {
+	_, err := c.do(ctx, http.MethodDelete, "/v1/users/"+strconv.Itoa(id), nil, nil)
+	return err
+}
+
+// Me returns the user the bearer token belongs to
+func (c *Client) Me(ctx context.Context) (User, error) {
+	var user User
+	_, err := c.do(ctx, http.MethodGet, "/v1/me", nil, &user)
+	return user, err
+}
+
+// nextPage returns the path of the rel="next" link, or "" on the last page
+func nextPage(header http.Header) string {
+	for _, link := range header.Values("Link") {
+		for _, value := range strings.Split(link, ",") {
+			target, params, ok := strings.Cut(value, ";")
+			if !ok || !strings.Contains(params, `rel="next"`) {
+				continue
+			}
+			target = strings.Trim(strings.TrimSpace(target), "<>")
+			if u, err := url.Parse(target); err == nil {
+				return u.RequestURI()
+			}
+		}
+	}
+	return ""
+}
@@ -3,12 +3,15 @@ module github.com/anishsharma21/go-web-dev-template
 go 1.23.4
 
 require (
+	github.com/BurntSushi/toml v1.5.0
+	github.com/andybalholm/brotli v1.2.6
 	github.com/clerk/clerk-sdk-go/v2 v2.3.1
 	github.com/google/uuid v1.6.0
 	github.com/jackc/pgx/v5 v5.7.2
-	github.com/lib/pq v1.10.9
+	github.com/klauspost/compress v1.18.2
 	github.com/pressly/goose/v3 v3.24.1
 	github.com/svix/svix-webhooks v1.66.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
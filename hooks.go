@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/apiversion"
+	"github.com/anishsharma21/go-web-dev-template/internal/backfill"
+	"github.com/anishsharma21/go-web-dev-template/internal/config"
+	"github.com/anishsharma21/go-web-dev-template/internal/db"
+	"github.com/anishsharma21/go-web-dev-template/internal/health"
+	"github.com/anishsharma21/go-web-dev-template/internal/lifecycle"
+	"github.com/anishsharma21/go-web-dev-template/internal/middleware"
+	"github.com/anishsharma21/go-web-dev-template/internal/setup"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// components are created by the lifecycle hooks as the server starts, and used by later hooks
+type components struct {
+	dbPool   *pgxpool.Pool
+	dbRouter *db.Router
+	migrator *setup.Migrator
+	health   *health.Checker
+}
+
+// databaseHook connects the primary and replica pools, and closes them once everything using
+// them has stopped
+func databaseHook(cfg *config.Config, c *components) lifecycle.Hook {
+	var replicaPool *pgxpool.Pool
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+
+	return lifecycle.Hook{
+		Name: "database",
+		Start: func(ctx context.Context) error {
+			dbPool, err := setup.DBPool(ctx, cfg)
+			if err != nil {
+				return err
+			}
+			replicaPool, err = setup.ReplicaPool(ctx, cfg)
+			if err != nil {
+				dbPool.Close()
+				return err
+			}
+			migrator, err := setup.NewMigrator(dbPool)
+			if err != nil {
+				dbPool.Close()
+				if replicaPool != nil {
+					replicaPool.Close()
+				}
+				return err
+			}
+
+			c.dbPool = dbPool
+			c.migrator = migrator
+			c.dbRouter = db.NewRouter(dbPool, replicaPool, cfg.Database.ReplicaMaxLag)
+			go c.dbRouter.Watch(watchCtx, cfg.Database.ReplicaCheckInterval)
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			stopWatch()
+			err := c.migrator.Close()
+			if replicaPool != nil {
+				replicaPool.Close()
+			}
+			c.dbPool.Close()
+			return err
+		},
+	}
+}
+
+// httpHook serves requests straight away, so the health endpoints answer during migrations while
+// readiness fails until startup has completed. On shutdown readiness fails first, so load
+// balancers stop routing new requests before the server stops accepting them.
+func httpHook(cfg *config.Config, c *components, app *lifecycle.Manager) lifecycle.Hook {
+	var server *http.Server
+	serverCtx, cancelServer := context.WithCancel(context.Background())
+	served := make(chan struct{})
+
+	return lifecycle.Hook{
+		Name:      "http",
+		DependsOn: []string{"database"},
+		Start: func(ctx context.Context) error {
+			jwksClient := middleware.NewJWKSClient(cfg.Clerk.SecretKey)
+			c.health = setup.HealthChecker(cfg, c.dbPool, c.migrator, jwksClient)
+
+			webhookSecret := cfg.SecretValue("CLERK_WEBHOOK_SIGNING_SECRET")
+			apiUsage := apiversion.NewUsage()
+			idempotencyKeys := db.NewIdempotencyKeys(c.dbPool, cfg.Idempotency.KeyTTL)
+
+			routes, err := setup.Routes(cfg, setup.Dependencies{
+				DB:              c.dbRouter,
+				JWKSClient:      jwksClient,
+				WebhookSecret:   webhookSecret,
+				Health:          c.health,
+				APIUsage:        apiUsage,
+				IdempotencyKeys: idempotencyKeys,
+			})
+			if err != nil {
+				return fmt.Errorf("Failed to setup routes: %w", err)
+			}
+
+			server = &http.Server{
+				Handler:           routes,
+				ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+				ReadTimeout:       cfg.HTTP.ReadTimeout,
+				WriteTimeout:      cfg.HTTP.WriteTimeout,
+				IdleTimeout:       cfg.HTTP.IdleTimeout,
+				BaseContext: func(l net.Listener) context.Context {
+					return serverCtx
+				},
+			}
+
+			// Listening before returning means a port already in use fails startup
+			listener, err := net.Listen("tcp", ":"+cfg.Port)
+			if err != nil {
+				return fmt.Errorf("Failed to listen on port %s: %w", cfg.Port, err)
+			}
+			slog.Info(fmt.Sprintf("Server started on http://%s", listener.Addr().String()))
+
+			// The background work starts once nothing can fail startup, as Stop isn't called for
+			// a hook whose Start failed and serverCtx would never be cancelled. Rotated webhook
+			// signing secrets are picked up without restarting.
+			go webhookSecret.Watch(serverCtx, cfg.Secrets.ReloadInterval)
+			// Logged hourly so that a deprecated API version can be removed once its traffic stops
+			go apiUsage.Report(serverCtx, time.Hour)
+			// Every instance deletes expired keys, deleting them twice is harmless
+			go idempotencyKeys.Cleanup(serverCtx, cfg.Idempotency.CleanupInterval)
+
+			go func() {
+				defer close(served)
+				if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+					slog.Error("HTTP server closed early", "error", err)
+					app.Shutdown(err)
+				}
+				slog.Info("Stopped serving new connections.")
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			defer cancelServer()
+
+			if cfg.Health.ShutdownDelay > 0 {
+				slog.Info("Waiting for load balancers to drain", "delay", cfg.Health.ShutdownDelay.String())
+			}
+			if err := c.health.Drain(ctx, cfg.Health.ShutdownDelay); err != nil {
+				return err
+			}
+
+			if err := server.Shutdown(ctx); err != nil {
+				return fmt.Errorf("HTTP shutdown error occurred: %w", err)
+			}
+			<-served
+			return nil
+		},
+		StopTimeout: cfg.Health.ShutdownDelay + cfg.HTTP.ShutdownTimeout,
+	}
+}
+
+// migrationsHook migrates the database, or only checks its version when RUN_MIGRATION isn't
+// set, then marks the server as started
+func migrationsHook(cfg *config.Config, c *components) lifecycle.Hook {
+	return lifecycle.Hook{
+		Name:      "migrations",
+		DependsOn: []string{"database", "http"},
+		Start: func(ctx context.Context) error {
+			if cfg.RunMigration {
+				// Replicas starting together wait for whichever one takes the lock to finish migrating
+				slog.Info("Attempting to run database migrations...")
+				err := c.migrator.WithLock(ctx, cfg.Migrations.LockTimeout, cfg.Migrations.LockPollInterval, c.migrator.Up)
+				if err != nil {
+					return fmt.Errorf("Failed to run database migrations: %w", err)
+				}
+				slog.Info("Database migrations complete.")
+			} else {
+				slog.Info("Database migrations skipped.")
+				if err := c.migrator.CheckVersion(ctx); err != nil {
+					return err
+				}
+			}
+
+			c.health.MarkStarted()
+			return nil
+		},
+	}
+}
+
+// backfillsHook runs scheduled backfills in the background. They stop as soon as shutdown starts,
+// an interrupted batch is rolled back and retried on the next start.
+func backfillsHook(cfg *config.Config, c *components) lifecycle.Hook {
+	backfillCtx, stopBackfills := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	return lifecycle.Hook{
+		Name:      "backfills",
+		DependsOn: []string{"migrations"},
+		Start: func(ctx context.Context) error {
+			if !cfg.Backfill.Enabled {
+				close(done)
+				return nil
+			}
+			go func() {
+				defer close(done)
+				backfill.NewRunner(c.dbPool).Run(backfillCtx, setup.Backfills(cfg)...)
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			stopBackfills()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return fmt.Errorf("Backfills didn't stop in time: %w", ctx.Err())
+			}
+		},
+	}
+}
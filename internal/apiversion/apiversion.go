@@ -0,0 +1,114 @@
+// Package apiversion describes the API versions served side by side, announces the deprecation
+// of old versions to clients and counts the requests each version receives.
+package apiversion
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal"
+)
+
+// Version is an API version, served under the /<name> path prefix
+type Version struct {
+	Name string
+	// Deprecated is when the version was deprecated, zero while it is supported
+	Deprecated time.Time
+	// Sunset is when the version will stop being served, zero if not yet scheduled
+	Sunset time.Time
+	// MigrationGuide is a URL explaining how to move to the next version, linked from responses
+	// once the version is deprecated
+	MigrationGuide string
+}
+
+// IsDeprecated reports whether the version has been deprecated by now
+func (v Version) IsDeprecated() bool {
+	return !v.Deprecated.IsZero() && !time.Now().Before(v.Deprecated)
+}
+
+// FromContext returns the API version of the request, or "" for unversioned routes
+func FromContext(ctx context.Context) string {
+	version, _ := ctx.Value(internal.API_VERSION_KEY).(string)
+	return version
+}
+
+// Usage counts the requests received by each version
+type Usage struct {
+	mu     sync.Mutex
+	counts map[string]*atomic.Int64
+}
+
+func NewUsage() *Usage {
+	return &Usage{counts: make(map[string]*atomic.Int64)}
+}
+
+// Middleware adds the version to the request context, so that it is logged with every record,
+// counts the request, and sets the Deprecation (RFC 9745) and Sunset (RFC 8594) headers once the
+// version is deprecated
+func (u *Usage) Middleware(v Version) func(http.Handler) http.Handler {
+	count := u.counter(v.Name)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			count.Add(1)
+
+			if v.IsDeprecated() {
+				w.Header().Set("Deprecation", fmt.Sprintf("@%d", v.Deprecated.Unix()))
+				if !v.Sunset.IsZero() {
+					w.Header().Set("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+				}
+				if v.MigrationGuide != "" {
+					w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="deprecation"; type="text/html"`, v.MigrationGuide))
+				}
+			}
+
+			ctx := context.WithValue(r.Context(), internal.API_VERSION_KEY, v.Name)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func (u *Usage) counter(name string) *atomic.Int64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.counts[name] == nil {
+		u.counts[name] = &atomic.Int64{}
+	}
+	return u.counts[name]
+}
+
+// Snapshot returns the number of requests each version has received since the last snapshot
+func (u *Usage) Snapshot() map[string]int64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	snapshot := make(map[string]int64, len(u.counts))
+	for name, count := range u.counts {
+		snapshot[name] = count.Swap(0)
+	}
+	return snapshot
+}
+
+// Report logs the requests each version received every interval until ctx is cancelled, so that
+// a deprecated version can be removed once its traffic has stopped
+func (u *Usage) Report(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			attrs := []any{slog.String("interval", interval.String())}
+			for name, count := range u.Snapshot() {
+				attrs = append(attrs, slog.Int64(name, count))
+			}
+			slog.Info("API version usage", attrs...)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Package apperr defines the domain errors shared by the db package and the handlers. Wrap them
+// with context, e.g. fmt.Errorf("user %s: %w", id, apperr.ErrNotFound), and the respond package
+// maps them to the matching HTTP status.
+package apperr
+
+import "errors"
+
+var (
+	// ErrInvalidInput means the request was malformed or failed validation (400)
+	ErrInvalidInput = errors.New("invalid input")
+	// ErrUnauthorized means the caller isn't authenticated (401)
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden means the caller isn't allowed to perform the action (403)
+	ErrForbidden = errors.New("forbidden")
+	// ErrNotFound means the resource doesn't exist (404)
+	ErrNotFound = errors.New("not found")
+	// ErrConflict means the action conflicts with the current state, such as a duplicate (409)
+	ErrConflict = errors.New("conflict")
+)
+
+// Error is a domain error with a message that is safe to show to clients, wrapping the underlying
+// error so that it is still logged in full
+type Error struct {
+	// Kind is one of the sentinel errors above
+	Kind    error
+	Message string
+	Err     error
+}
+
+// New creates an Error of the kind, wrapping err which may be nil
+func New(kind error, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
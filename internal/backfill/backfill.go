@@ -0,0 +1,179 @@
+// Package backfill runs long data migrations in the background, in small throttled batches, so
+// they don't block deploys. Progress is stored in the backfill_progress table, which makes jobs
+// resumable after a restart and safe to run from several instances at once.
+package backfill
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Job is a backfill which walks a table in ascending order of its id column
+type Job struct {
+	// Name identifies the job's progress and must match the name passed to Schedule
+	Name string
+	// Table is the table whose rows are backfilled
+	Table string
+	// BatchSize is the number of rows passed to Process at a time
+	BatchSize int
+	// Pause between batches throttles the job so it doesn't compete with requests
+	Pause time.Duration
+	// Prepare, if set, does the slow work for a batch, such as calling another service, before the
+	// transaction starts, so that it doesn't hold a connection and the progress lock. Its result
+	// is passed to Process. It may run again for the same rows if the batch is retried.
+	Prepare func(ctx context.Context, dbPool *pgxpool.Pool, ids []int64) (any, error)
+	// Process backfills a batch of rows. It runs in the transaction that records the progress, so
+	// a batch is either fully applied and recorded, or retried when the job is next run.
+	Process func(ctx context.Context, tx pgx.Tx, ids []int64, prepared any) error
+}
+
+// Schedule queues a job from a Go migration. The migration finishes straight away and the job is
+// run by the Runner once the server has started.
+func Schedule(ctx context.Context, tx *sql.Tx, name string) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO backfill_progress (name) VALUES ($1) ON CONFLICT (name) DO NOTHING", name)
+	if err != nil {
+		return fmt.Errorf("failed to schedule backfill %q: %w", name, err)
+	}
+	return nil
+}
+
+// Unschedule removes a job and its progress, for use in the down migration
+func Unschedule(ctx context.Context, tx *sql.Tx, name string) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM backfill_progress WHERE name = $1", name)
+	if err != nil {
+		return fmt.Errorf("failed to unschedule backfill %q: %w", name, err)
+	}
+	return nil
+}
+
+// Runner runs scheduled backfill jobs
+type Runner struct {
+	dbPool *pgxpool.Pool
+}
+
+func NewRunner(dbPool *pgxpool.Pool) *Runner {
+	return &Runner{dbPool: dbPool}
+}
+
+// Run runs each scheduled job that hasn't completed, one after another, until they are done or
+// ctx is cancelled. A failed job is logged and picks up from its last batch the next time it runs.
+func (r *Runner) Run(ctx context.Context, jobs ...Job) {
+	for _, job := range jobs {
+		if err := r.run(ctx, job); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("Backfill failed, it will resume on the next start", "job", job.Name, "error", err)
+		}
+	}
+}
+
+func (r *Runner) run(ctx context.Context, job Job) error {
+	for {
+		done, err := r.batch(ctx, job)
+		if err != nil || done {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(job.Pause):
+		}
+	}
+}
+
+// batch processes the next batch of the job and reports whether the job has nothing left to do
+func (r *Runner) batch(ctx context.Context, job Job) (bool, error) {
+	// The batch is selected and prepared without holding the progress lock, which is only taken
+	// to apply it. If another instance has recorded progress in the meantime, the batch is dropped
+	// and the next one is selected.
+	var lastID int64
+	var completedAt *time.Time
+	err := r.dbPool.QueryRow(ctx, "SELECT last_id, completed_at FROM backfill_progress WHERE name = $1", job.Name).
+		Scan(&lastID, &completedAt)
+	if errors.Is(err, pgx.ErrNoRows) || completedAt != nil {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read progress: %w", err)
+	}
+	if lastID == 0 {
+		slog.Info("Starting backfill", "job", job.Name)
+	}
+
+	query := fmt.Sprintf("SELECT id FROM %s WHERE id > $1 ORDER BY id LIMIT $2", pgx.Identifier{job.Table}.Sanitize())
+	rows, err := r.dbPool.Query(ctx, query, lastID, job.BatchSize)
+	if err != nil {
+		return false, fmt.Errorf("failed to select batch after id %d: %w", lastID, err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return false, fmt.Errorf("failed to select batch after id %d: %w", lastID, err)
+	}
+
+	var prepared any
+	if len(ids) > 0 && job.Prepare != nil {
+		if prepared, err = job.Prepare(ctx, r.dbPool, ids); err != nil {
+			return false, fmt.Errorf("failed to prepare batch after id %d: %w", lastID, err)
+		}
+	}
+
+	tx, err := r.dbPool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Locking the progress row means instances running the same job take turns, and each batch
+	// starts where the previous one, from whichever instance, left off
+	var lockedLastID, rowsProcessed int64
+	err = tx.QueryRow(ctx, "SELECT last_id, rows_processed, completed_at FROM backfill_progress WHERE name = $1 FOR UPDATE", job.Name).
+		Scan(&lockedLastID, &rowsProcessed, &completedAt)
+	if errors.Is(err, pgx.ErrNoRows) || completedAt != nil {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock progress: %w", err)
+	}
+	if lockedLastID != lastID {
+		slog.Debug("Backfill batch already processed by another instance", "job", job.Name, "last_id", lastID)
+		return false, nil
+	}
+
+	if len(ids) == 0 {
+		_, err = tx.Exec(ctx, "UPDATE backfill_progress SET completed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE name = $1", job.Name)
+		if err != nil {
+			return false, fmt.Errorf("failed to mark backfill complete: %w", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return false, fmt.Errorf("failed to commit: %w", err)
+		}
+		slog.Info("Backfill complete", "job", job.Name, "rows_processed", rowsProcessed)
+		return true, nil
+	}
+
+	if err := job.Process(ctx, tx, ids, prepared); err != nil {
+		return false, fmt.Errorf("failed to process batch after id %d: %w", lastID, err)
+	}
+
+	lastID = ids[len(ids)-1]
+	_, err = tx.Exec(ctx, "UPDATE backfill_progress SET last_id = $2, rows_processed = rows_processed + $3, updated_at = CURRENT_TIMESTAMP WHERE name = $1",
+		job.Name, lastID, len(ids))
+	if err != nil {
+		return false, fmt.Errorf("failed to record progress: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit: %w", err)
+	}
+
+	slog.Debug("Backfilled batch", "job", job.Name, "last_id", lastID, "rows", len(ids))
+	return false, nil
+}
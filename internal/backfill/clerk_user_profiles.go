@@ -0,0 +1,86 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/retry"
+	"github.com/clerk/clerk-sdk-go/v2"
+	"github.com/clerk/clerk-sdk-go/v2/user"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ClerkUserProfilesJob copies the email and name of users created before they were stored
+const ClerkUserProfilesJob = "clerk_user_profiles"
+
+// ClerkUserProfiles creates the job which fetches each batch of users from Clerk in one request
+// and stores their primary email address and name
+func ClerkUserProfiles(clerkUsers *user.Client, batchSize int, pause time.Duration) Job {
+	return Job{
+		Name:      ClerkUserProfilesJob,
+		Table:     "users",
+		BatchSize: batchSize,
+		Pause:     pause,
+		// Clerk is called before the batch transaction, as retries can take up to 30 seconds
+		Prepare: func(ctx context.Context, dbPool *pgxpool.Pool, ids []int64) (any, error) {
+			rows, err := dbPool.Query(ctx, "SELECT clerk_id FROM users WHERE id = ANY($1)", ids)
+			if err != nil {
+				return nil, fmt.Errorf("failed to select clerk ids: %w", err)
+			}
+			clerkIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+			if err != nil {
+				return nil, fmt.Errorf("failed to select clerk ids: %w", err)
+			}
+
+			params := &user.ListParams{UserIDs: clerkIDs}
+			params.Limit = clerk.Int64(int64(len(clerkIDs)))
+			var list *clerk.UserList
+			err = retry.Do(ctx, clerkRetryPolicy(), func(ctx context.Context) error {
+				list, err = clerkUsers.List(ctx, params)
+				return err
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list users from Clerk: %w", err)
+			}
+			return list.Users, nil
+		},
+		Process: func(ctx context.Context, tx pgx.Tx, ids []int64, prepared any) error {
+			// Users deleted from Clerk are left as they are
+			for _, clerkUser := range prepared.([]*clerk.User) {
+				_, err := tx.Exec(ctx, "UPDATE users SET email = $2, first_name = $3, last_name = $4, updated_at = CURRENT_TIMESTAMP WHERE clerk_id = $1",
+					clerkUser.ID, primaryEmailAddress(clerkUser), clerkUser.FirstName, clerkUser.LastName)
+				if err != nil {
+					return fmt.Errorf("failed to update user %q: %w", clerkUser.ID, err)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func primaryEmailAddress(clerkUser *clerk.User) *string {
+	for _, emailAddress := range clerkUser.EmailAddresses {
+		if clerkUser.PrimaryEmailAddressID != nil && emailAddress.ID == *clerkUser.PrimaryEmailAddressID {
+			return &emailAddress.EmailAddress
+		}
+	}
+	return nil
+}
+
+// clerkRetryPolicy retries rate limited and failed requests to Clerk for up to 30 seconds
+func clerkRetryPolicy() retry.Policy {
+	policy := retry.DefaultPolicy()
+	policy.MaxElapsed = 30 * time.Second
+	policy.Retryable = func(err error) bool {
+		var apiErr *clerk.APIErrorResponse
+		if errors.As(err, &apiErr) {
+			return retry.HTTPStatusRetryable(apiErr.HTTPStatusCode)
+		}
+		// Otherwise the request didn't get a response, e.g. a timeout or a dropped connection
+		return !errors.Is(err, context.Canceled)
+	}
+	return policy
+}
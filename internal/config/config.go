@@ -0,0 +1,428 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/anishsharma21/go-web-dev-template/internal/secrets"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds all application configuration. It is loaded once at startup by Load and passed
+// explicitly to the packages that need it. Each field can be set in the YAML or TOML config file
+// (yaml tag) and overridden by an environment variable (env tag) or a file named by the same
+// variable with a _FILE suffix. Fields with a secret tag are also looked up in the secrets provider.
+type Config struct {
+	Environment  string `yaml:"environment" env:"ENVIRONMENT"`
+	Port         string `yaml:"port" env:"PORT"`
+	DatabaseURL  string `yaml:"database_url" env:"DATABASE_URL" secret:"database_url"`
+	RunMigration bool   `yaml:"run_migration" env:"RUN_MIGRATION"`
+
+	Database   DatabaseConfig   `yaml:"database"`
+	Clerk      ClerkConfig      `yaml:"clerk"`
+	HTTP       HTTPConfig       `yaml:"http"`
+	CORS       CORSConfig       `yaml:"cors"`
+	Security   SecurityConfig   `yaml:"security"`
+	Secrets    SecretsConfig    `yaml:"secrets"`
+	Health     HealthConfig     `yaml:"health"`
+	Migrations MigrationsConfig `yaml:"migrations"`
+	Backfill   BackfillConfig   `yaml:"backfill"`
+
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+
+	// secretLoaders re-read secrets which came from a file or the secrets provider, keyed by env key
+	secretLoaders map[string]secrets.LoadFunc
+}
+
+// DatabaseConfig configures the connection pools. Zero values keep the pgxpool defaults.
+type DatabaseConfig struct {
+	MaxConns              int           `yaml:"max_conns" env:"DB_MAX_CONNS"`
+	MinConns              int           `yaml:"min_conns" env:"DB_MIN_CONNS"`
+	MaxConnLifetime       time.Duration `yaml:"max_conn_lifetime" env:"DB_MAX_CONN_LIFETIME"`
+	MaxConnLifetimeJitter time.Duration `yaml:"max_conn_lifetime_jitter" env:"DB_MAX_CONN_LIFETIME_JITTER"`
+	MaxConnIdleTime       time.Duration `yaml:"max_conn_idle_time" env:"DB_MAX_CONN_IDLE_TIME"`
+	HealthCheckPeriod     time.Duration `yaml:"health_check_period" env:"DB_HEALTH_CHECK_PERIOD"`
+	ConnectTimeout        time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT"`
+	// StartupTimeout is how long to keep retrying the first connection to the primary on startup
+	StartupTimeout time.Duration `yaml:"startup_timeout" env:"DB_STARTUP_TIMEOUT"`
+	// QueryExecMode is one of cache_statement (default), cache_describe, describe_exec, exec or
+	// simple_protocol. PgBouncer in transaction pooling mode needs exec or simple_protocol, as
+	// prepared statements don't survive a connection being handed to another client.
+	QueryExecMode string `yaml:"query_exec_mode" env:"DB_QUERY_EXEC_MODE"`
+
+	// ReplicaURL optionally points read-only queries at a read replica
+	ReplicaURL string `yaml:"replica_url" env:"DATABASE_REPLICA_URL" secret:"database_replica_url"`
+	// ReplicaMaxLag is how far behind the primary the replica may be before reads fall back to the primary
+	ReplicaMaxLag time.Duration `yaml:"replica_max_lag" env:"DB_REPLICA_MAX_LAG"`
+	// ReplicaCheckInterval is how often the replica's health and lag are checked
+	ReplicaCheckInterval time.Duration `yaml:"replica_check_interval" env:"DB_REPLICA_CHECK_INTERVAL"`
+}
+
+type ClerkConfig struct {
+	SecretKey string `yaml:"secret_key" env:"CLERK_SECRET_KEY" secret:"clerk_secret_key"`
+	// WebhookSigningSecret holds one or more secrets separated by commas or whitespace. While
+	// rotating, list both the old and the new secret so no deliveries are rejected.
+	WebhookSigningSecret string `yaml:"webhook_signing_secret" env:"CLERK_WEBHOOK_SIGNING_SECRET" secret:"clerk_webhook_signing_secret"`
+}
+
+type HTTPConfig struct {
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	// WriteTimeout must be longer than the slowest route timeout, otherwise the connection is
+	// closed before the handler's timeout response can be written
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT"`
+	// ShutdownTimeout is how long in-flight requests have to finish on shutdown
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT"`
+	// CompressionMinSize is the smallest response body in bytes worth compressing
+	CompressionMinSize int `yaml:"compression_min_size" env:"HTTP_COMPRESSION_MIN_SIZE"`
+	// TrustedProxies are CIDRs or IP addresses whose forwarding headers (X-Forwarded-For,
+	// X-Forwarded-Proto, Forwarded, X-Real-IP) are trusted
+	TrustedProxies []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+}
+
+type CORSConfig struct {
+	// AllowedOrigins may contain exact origins, wildcard subdomains ("https://*.example.com") or "*"
+	AllowedOrigins   []string      `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	AllowedMethods   []string      `yaml:"allowed_methods" env:"CORS_ALLOWED_METHODS"`
+	AllowedHeaders   []string      `yaml:"allowed_headers" env:"CORS_ALLOWED_HEADERS"`
+	ExposedHeaders   []string      `yaml:"exposed_headers" env:"CORS_EXPOSED_HEADERS"`
+	AllowCredentials bool          `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           time.Duration `yaml:"max_age" env:"CORS_MAX_AGE"`
+}
+
+type SecurityConfig struct {
+	HSTSMaxAge            time.Duration `yaml:"hsts_max_age" env:"SECURITY_HSTS_MAX_AGE"`
+	HSTSIncludeSubdomains bool          `yaml:"hsts_include_subdomains" env:"SECURITY_HSTS_INCLUDE_SUBDOMAINS"`
+	HSTSPreload           bool          `yaml:"hsts_preload" env:"SECURITY_HSTS_PRELOAD"`
+	// An empty ContentSecurityPolicy, FrameOptions or ReferrerPolicy removes the header
+	ContentSecurityPolicy string `yaml:"content_security_policy" env:"SECURITY_CSP"`
+	FrameOptions          string `yaml:"frame_options" env:"SECURITY_FRAME_OPTIONS"`
+	ReferrerPolicy        string `yaml:"referrer_policy" env:"SECURITY_REFERRER_POLICY"`
+	HTTPSRedirect         bool   `yaml:"https_redirect" env:"HTTPS_REDIRECT"`
+}
+
+type SecretsConfig struct {
+	// Dir is read by the file based secrets provider, each secret is a file named after its
+	// secret tag, e.g. /run/secrets/clerk_webhook_signing_secret
+	Dir string `yaml:"dir" env:"SECRETS_DIR"`
+	// ReloadInterval is how often rotatable secrets are re-read (0 disables reloading)
+	ReloadInterval time.Duration `yaml:"reload_interval" env:"SECRETS_RELOAD_INTERVAL"`
+}
+
+type HealthConfig struct {
+	// CheckTimeout bounds each readiness check
+	CheckTimeout time.Duration `yaml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+	// ShutdownDelay is how long readiness fails before the server stops accepting connections,
+	// giving load balancers time to stop routing traffic to the process
+	ShutdownDelay time.Duration `yaml:"shutdown_delay" env:"HEALTH_SHUTDOWN_DELAY"`
+	// JWKSCacheTTL is how long a successful Clerk JWKS fetch keeps the check passing when Clerk
+	// is unreachable, matching how long verified keys are cached by the auth middleware
+	JWKSCacheTTL time.Duration `yaml:"jwks_cache_ttl" env:"HEALTH_JWKS_CACHE_TTL"`
+}
+
+type MigrationsConfig struct {
+	// LockTimeout is how long to wait for another instance holding the migration lock before
+	// giving up, so only one replica migrates when several start at once
+	LockTimeout time.Duration `yaml:"lock_timeout" env:"MIGRATION_LOCK_TIMEOUT"`
+	// LockPollInterval is how often the lock is retried while another instance holds it
+	LockPollInterval time.Duration `yaml:"lock_poll_interval" env:"MIGRATION_LOCK_POLL_INTERVAL"`
+}
+
+type BackfillConfig struct {
+	// Enabled runs scheduled backfills in the background once the server has started
+	Enabled bool `yaml:"enabled" env:"BACKFILL_ENABLED"`
+	// BatchSize is the number of rows updated per transaction, at most 500 as users are fetched
+	// from Clerk in one request per batch
+	BatchSize int `yaml:"batch_size" env:"BACKFILL_BATCH_SIZE"`
+	// Pause between batches throttles backfills so they don't compete with requests
+	Pause time.Duration `yaml:"pause" env:"BACKFILL_PAUSE"`
+}
+
+type IdempotencyConfig struct {
+	// KeyTTL is how long the response to a request with an Idempotency-Key is replayed for repeats
+	// of it, clients must not retry with the same key after it
+	KeyTTL time.Duration `yaml:"key_ttl" env:"IDEMPOTENCY_KEY_TTL"`
+	// CleanupInterval is how often expired keys are deleted (0 disables cleanup)
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env:"IDEMPOTENCY_CLEANUP_INTERVAL"`
+}
+
+const (
+	Development = "development"
+	Test        = "test"
+	Staging     = "staging"
+	Production  = "production"
+)
+
+// Defaults returns the default configuration for an environment
+func Defaults(environment string) Config {
+	cfg := Config{
+		Environment: environment,
+		Port:        "8080",
+		Database: DatabaseConfig{
+			MinConns:             1,
+			MaxConnIdleTime:      time.Minute,
+			StartupTimeout:       time.Minute,
+			QueryExecMode:        "cache_statement",
+			ReplicaMaxLag:        10 * time.Second,
+			ReplicaCheckInterval: 5 * time.Second,
+		},
+		Secrets: SecretsConfig{
+			ReloadInterval: 30 * time.Second,
+		},
+		Health: HealthConfig{
+			CheckTimeout: 2 * time.Second,
+			JWKSCacheTTL: time.Hour,
+		},
+		Migrations: MigrationsConfig{
+			LockTimeout:      5 * time.Minute,
+			LockPollInterval: 2 * time.Second,
+		},
+		Backfill: BackfillConfig{
+			Enabled:   true,
+			BatchSize: 100,
+			Pause:     time.Second,
+		},
+		Idempotency: IdempotencyConfig{
+			KeyTTL:          24 * time.Hour,
+			CleanupInterval: time.Hour,
+		},
+		HTTP: HTTPConfig{
+			ReadHeaderTimeout:  5 * time.Second,
+			ReadTimeout:        15 * time.Second,
+			WriteTimeout:       30 * time.Second,
+			IdleTimeout:        60 * time.Second,
+			ShutdownTimeout:    10 * time.Second,
+			CompressionMinSize: 1024,
+		},
+		CORS: CORSConfig{
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders: []string{"Authorization", "Content-Type", "Idempotency-Key"},
+			MaxAge:         10 * time.Minute,
+		},
+		Security: SecurityConfig{
+			// The API only serves JSON, so nothing it returns should load resources or be framed
+			ContentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+			FrameOptions:          "DENY",
+			ReferrerPolicy:        "strict-origin-when-cross-origin",
+		},
+	}
+
+	switch environment {
+	case Development, Test:
+		cfg.CORS.AllowedOrigins = []string{"http://localhost:3000", "http://localhost:5173"}
+	case Staging, Production:
+		cfg.Security.HSTSMaxAge = 365 * 24 * time.Hour
+		cfg.Security.HSTSIncludeSubdomains = true
+		cfg.Health.ShutdownDelay = 5 * time.Second
+	}
+
+	return cfg
+}
+
+// Option customises how Load and LoadDatabase build the configuration
+type Option func(*options)
+
+type options struct {
+	secretsProviders []secrets.Provider
+}
+
+// WithSecretsProvider looks up secrets which are still unset in an external secret store, after
+// the file based provider reading SECRETS_DIR. Secrets it returns are reloaded like those read
+// from files.
+func WithSecretsProvider(provider secrets.Provider) Option {
+	return func(o *options) {
+		o.secretsProviders = append(o.secretsProviders, provider)
+	}
+}
+
+// Load builds the configuration from, in increasing order of precedence, the defaults for the
+// environment, an optional YAML or TOML file (-config flag or CONFIG_FILE), environment variables and
+// command line flags. All validation errors are reported together.
+func Load(args []string, opts ...Option) (*Config, error) {
+	cfg, rest, err := load(args, (*Config).Validate, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("Unexpected arguments: %s", strings.Join(rest, " "))
+	}
+
+	if cfg.Clerk.WebhookSigningSecret == "" {
+		slog.Warn("CLERK_WEBHOOK_SIGNING_SECRET not set, webhooks will be rejected")
+	}
+	if len(cfg.CORS.AllowedOrigins) == 0 {
+		slog.Warn("CORS_ALLOWED_ORIGINS not set, cross-origin requests will be rejected")
+	}
+
+	return cfg, nil
+}
+
+// LoadDatabase loads the configuration like Load but only validates the database settings, for
+// commands such as migrate which don't serve requests. It returns the arguments left after the flags.
+func LoadDatabase(args []string, opts ...Option) (*Config, []string, error) {
+	return load(args, (*Config).ValidateDatabase, opts...)
+}
+
+func load(args []string, validate func(*Config) error, opts ...Option) (*Config, []string, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	environment := fs.String("env", "", "environment (development, test, staging or production)")
+	port := fs.String("port", "", "port to listen on")
+	runMigration := fs.Bool("run-migration", false, "run database migrations on startup")
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, fmt.Errorf("Failed to parse flags: %w", err)
+	}
+
+	var file []byte
+	if *configFile != "" {
+		var err error
+		file, err = readConfigFile(*configFile)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// The environment selects the defaults, so it has to be resolved before everything else
+	env := *environment
+	if env == "" {
+		env = os.Getenv("ENVIRONMENT")
+	}
+	if env == "" && file != nil {
+		var peek struct {
+			Environment string `yaml:"environment"`
+		}
+		if err := yaml.Unmarshal(file, &peek); err != nil {
+			return nil, nil, fmt.Errorf("Failed to parse config file %s: %w", *configFile, err)
+		}
+		env = peek.Environment
+	}
+	if env == "" {
+		env = Development
+	}
+
+	cfg := Defaults(env)
+	if file != nil {
+		if err := yaml.Unmarshal(file, &cfg); err != nil {
+			return nil, nil, fmt.Errorf("Failed to parse config file %s: %w", *configFile, err)
+		}
+	}
+	loaders, err := applyEnv(&cfg, os.LookupEnv)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.secretLoaders = loaders
+	cfg.Environment = env
+
+	providers := o.secretsProviders
+	if cfg.Secrets.Dir != "" {
+		providers = append([]secrets.Provider{secrets.FileProvider{Dir: cfg.Secrets.Dir}}, providers...)
+	}
+	for _, provider := range providers {
+		if err := cfg.applySecrets(context.Background(), provider); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.Port = *port
+		case "run-migration":
+			cfg.RunMigration = *runMigration
+		}
+	})
+
+	if err := validate(&cfg); err != nil {
+		return nil, nil, err
+	}
+
+	return &cfg, fs.Args(), nil
+}
+
+// applySecrets fills secret fields which haven't been set by the file or environment from the provider
+func (c *Config) applySecrets(ctx context.Context, provider secrets.Provider) error {
+	var errs []error
+	walkFields(reflect.ValueOf(c).Elem(), func(field reflect.Value, tag reflect.StructTag) {
+		name := tag.Get("secret")
+		if name == "" || field.String() != "" {
+			return
+		}
+
+		load := secrets.FromProvider(provider, name)
+		value, err := load(ctx)
+		if errors.Is(err, secrets.ErrNotFound) {
+			return
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to read secret %s: %w", name, err))
+			return
+		}
+		field.SetString(value)
+		c.secretLoaders[tag.Get("env")] = load
+	})
+	return errors.Join(errs...)
+}
+
+// SecretValue returns the secret set by the given environment variable key as a value that can
+// be reloaded, if it was read from a file or the secrets provider, to pick up rotations
+func (c *Config) SecretValue(envKey string) *secrets.Value {
+	var current string
+	walkFields(reflect.ValueOf(c).Elem(), func(field reflect.Value, tag reflect.StructTag) {
+		if tag.Get("env") == envKey {
+			current = field.String()
+		}
+	})
+	return secrets.NewValue(envKey, current, c.secretLoaders[envKey])
+}
+
+// IsProduction reports whether the configuration is for a production-like environment
+func (c *Config) IsProduction() bool {
+	return c.Environment == Production || c.Environment == Staging
+}
+
+// ErrInvalid is wrapped by all validation errors
+var ErrInvalid = errors.New("invalid configuration")
+
+// readConfigFile reads a YAML or TOML config file and returns it as YAML, so that both formats
+// are keyed by the yaml tags of Config. Other formats are rejected, reading them as YAML would fail
+// confusingly or, worse, silently ignore the file's settings.
+func readConfigFile(path string) ([]byte, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".yaml" && ext != ".yml" && ext != ".toml" {
+		return nil, fmt.Errorf("Unsupported config file %s: only YAML (.yaml or .yml) and TOML (.toml) files are supported", path)
+	}
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read config file: %w", err)
+	}
+	if ext != ".toml" {
+		return file, nil
+	}
+
+	var values map[string]any
+	if err := toml.Unmarshal(file, &values); err != nil {
+		return nil, fmt.Errorf("Failed to parse config file %s: %w", path, err)
+	}
+	file, err = yaml.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to convert config file %s: %w", path, err)
+	}
+	return file, nil
+}
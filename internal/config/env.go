@@ -0,0 +1,110 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/secrets"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnv walks the config struct and sets every field with an env tag whose environment
+// variable, or the file named by the variable with a _FILE suffix, is set. Lists are comma
+// separated, durations use time.ParseDuration syntax. It returns loaders for the fields read
+// from files so they can be reloaded.
+func applyEnv(cfg *Config, lookupEnv func(string) (string, bool)) (map[string]secrets.LoadFunc, error) {
+	loaders := make(map[string]secrets.LoadFunc)
+	var errs []error
+	walkFields(reflect.ValueOf(cfg).Elem(), func(field reflect.Value, tag reflect.StructTag) {
+		key := tag.Get("env")
+		value, ok := lookupEnv(key)
+		path, fromFile := lookupEnv(key + "_FILE")
+		if ok && fromFile {
+			errs = append(errs, fmt.Errorf("%w: only one of %s and %s_FILE can be set", ErrInvalid, key, key))
+			return
+		}
+
+		if fromFile {
+			var err error
+			if value, err = secrets.ReadFile(path); err != nil {
+				errs = append(errs, fmt.Errorf("%w: %s_FILE: %v", ErrInvalid, key, err))
+				return
+			}
+			loaders[key] = secrets.FromFile(path)
+		} else if !ok {
+			return
+		}
+
+		if err := setField(field, value); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s=%q: %v", ErrInvalid, key, value, err))
+		}
+	})
+	return loaders, errors.Join(errs...)
+}
+
+// walkFields calls fn for every field with an env tag, descending into nested structs
+func walkFields(v reflect.Value, fn func(field reflect.Value, tag reflect.StructTag)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		if tag := t.Field(i).Tag; tag.Get("env") != "" {
+			fn(field, tag)
+			continue
+		}
+		if field.Kind() == reflect.Struct {
+			walkFields(field, fn)
+		}
+	}
+}
+
+func setField(field reflect.Value, value string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		// An empty boolean means false so that RUN_MIGRATION= behaves like it isn't set to true
+		if value == "" {
+			field.SetBool(false)
+			return nil
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported config field type %s", field.Type())
+	}
+	return nil
+}
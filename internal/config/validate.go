@@ -0,0 +1,157 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validate checks the configuration and returns all problems joined into a single error
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, invalidf(format, args...))
+	}
+
+	if err := c.ValidateDatabase(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		invalid("PORT must be a number between 1 and 65535, got %q", c.Port)
+	}
+
+	if c.Clerk.SecretKey == "" {
+		invalid("CLERK_SECRET_KEY not set")
+	}
+	if c.Clerk.WebhookSigningSecret == "" && c.IsProduction() {
+		invalid("CLERK_WEBHOOK_SIGNING_SECRET not set")
+	}
+
+	durations := map[string]time.Duration{
+		"HTTP_READ_HEADER_TIMEOUT":     c.HTTP.ReadHeaderTimeout,
+		"HTTP_READ_TIMEOUT":            c.HTTP.ReadTimeout,
+		"HTTP_WRITE_TIMEOUT":           c.HTTP.WriteTimeout,
+		"HTTP_IDLE_TIMEOUT":            c.HTTP.IdleTimeout,
+		"HTTP_SHUTDOWN_TIMEOUT":        c.HTTP.ShutdownTimeout,
+		"CORS_MAX_AGE":                 c.CORS.MaxAge,
+		"SECURITY_HSTS_MAX_AGE":        c.Security.HSTSMaxAge,
+		"SECRETS_RELOAD_INTERVAL":      c.Secrets.ReloadInterval,
+		"HEALTH_CHECK_TIMEOUT":         c.Health.CheckTimeout,
+		"HEALTH_SHUTDOWN_DELAY":        c.Health.ShutdownDelay,
+		"HEALTH_JWKS_CACHE_TTL":        c.Health.JWKSCacheTTL,
+		"BACKFILL_PAUSE":               c.Backfill.Pause,
+		"IDEMPOTENCY_CLEANUP_INTERVAL": c.Idempotency.CleanupInterval,
+	}
+	for key, duration := range durations {
+		if duration < 0 {
+			invalid("%s must not be negative, got %s", key, duration)
+		}
+	}
+	if c.Backfill.BatchSize < 1 || c.Backfill.BatchSize > 500 {
+		invalid("BACKFILL_BATCH_SIZE must be between 1 and 500, got %d", c.Backfill.BatchSize)
+	}
+	if c.Idempotency.KeyTTL <= 0 {
+		invalid("IDEMPOTENCY_KEY_TTL must be positive, got %s", c.Idempotency.KeyTTL)
+	}
+	if c.HTTP.CompressionMinSize < 0 {
+		invalid("HTTP_COMPRESSION_MIN_SIZE must not be negative, got %d", c.HTTP.CompressionMinSize)
+	}
+
+	if _, err := c.HTTP.TrustedProxyPrefixes(); err != nil {
+		invalid("TRUSTED_PROXIES: %v", err)
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			invalid("CORS_ALLOWED_ORIGINS entries must start with http:// or https://, got %q", origin)
+		}
+	}
+	if c.CORS.AllowCredentials && slices.Contains(c.CORS.AllowedOrigins, "*") {
+		invalid("CORS_ALLOWED_ORIGINS must list origins explicitly when CORS_ALLOW_CREDENTIALS is set, got \"*\"")
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateDatabase checks only the environment and the settings needed to connect to and migrate
+// the database
+func (c *Config) ValidateDatabase() error {
+	var errs []error
+
+	switch c.Environment {
+	case Development, Test, Staging, Production:
+	default:
+		errs = append(errs, invalidf("ENVIRONMENT must be one of development, test, staging or production, got %q", c.Environment))
+	}
+
+	if c.DatabaseURL == "" {
+		errs = append(errs, invalidf("DATABASE_URL not set"))
+	}
+	durations := map[string]time.Duration{
+		"DB_MAX_CONN_LIFETIME":        c.Database.MaxConnLifetime,
+		"DB_MAX_CONN_LIFETIME_JITTER": c.Database.MaxConnLifetimeJitter,
+		"DB_MAX_CONN_IDLE_TIME":       c.Database.MaxConnIdleTime,
+		"DB_HEALTH_CHECK_PERIOD":      c.Database.HealthCheckPeriod,
+		"DB_CONNECT_TIMEOUT":          c.Database.ConnectTimeout,
+		"DB_STARTUP_TIMEOUT":          c.Database.StartupTimeout,
+		"DB_REPLICA_MAX_LAG":          c.Database.ReplicaMaxLag,
+	}
+	for key, duration := range durations {
+		if duration < 0 {
+			errs = append(errs, invalidf("%s must not be negative, got %s", key, duration))
+		}
+	}
+	if c.Database.MaxConns < 0 || c.Database.MinConns < 0 {
+		errs = append(errs, invalidf("DB_MAX_CONNS and DB_MIN_CONNS must not be negative, got %d and %d", c.Database.MaxConns, c.Database.MinConns))
+	}
+	if c.Database.MaxConns > 0 && c.Database.MinConns > c.Database.MaxConns {
+		errs = append(errs, invalidf("DB_MIN_CONNS (%d) must not be greater than DB_MAX_CONNS (%d)", c.Database.MinConns, c.Database.MaxConns))
+	}
+	switch c.Database.QueryExecMode {
+	case "", "cache_statement", "cache_describe", "describe_exec", "exec", "simple_protocol":
+	default:
+		errs = append(errs, invalidf("DB_QUERY_EXEC_MODE must be one of cache_statement, cache_describe, describe_exec, exec or simple_protocol, got %q", c.Database.QueryExecMode))
+	}
+	if c.Database.ReplicaURL != "" && c.Database.ReplicaCheckInterval <= 0 {
+		errs = append(errs, invalidf("DB_REPLICA_CHECK_INTERVAL must be positive, got %s", c.Database.ReplicaCheckInterval))
+	}
+	if c.Migrations.LockTimeout < 0 {
+		errs = append(errs, invalidf("MIGRATION_LOCK_TIMEOUT must not be negative, got %s", c.Migrations.LockTimeout))
+	}
+	if c.Migrations.LockPollInterval <= 0 {
+		errs = append(errs, invalidf("MIGRATION_LOCK_POLL_INTERVAL must be positive, got %s", c.Migrations.LockPollInterval))
+	}
+
+	return errors.Join(errs...)
+}
+
+func invalidf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalid, fmt.Sprintf(format, args...))
+}
+
+// TrustedProxyPrefixes parses TrustedProxies, single IP addresses become /32 or /128 prefixes
+func (c HTTPConfig) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, value := range c.TrustedProxies {
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
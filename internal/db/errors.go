@@ -0,0 +1,76 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/apperr"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Postgres error codes translated into domain errors, see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	uniqueViolation           = "23505"
+	foreignKeyViolation       = "23503"
+	invalidTextRepresentation = "22P02"
+	numericValueOutOfRange    = "22003"
+	stringDataRightTruncation = "22001"
+)
+
+// TranslateError turns errors the client caused into apperr errors, so that handlers respond with
+// 404, 409 or 400 instead of 500. The resource, e.g. "user", is used in the client-facing message.
+// Other errors, such as lost connections, are returned unchanged. It is exported for queries run
+// outside this package.
+func TranslateError(err error, resource string) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperr.New(apperr.ErrNotFound, resource+" not found", err)
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case uniqueViolation:
+		return apperr.New(apperr.ErrConflict, resource+" already exists", err)
+	case foreignKeyViolation:
+		return apperr.New(apperr.ErrConflict, resource+" conflicts with a related record", err)
+	case invalidTextRepresentation, numericValueOutOfRange, stringDataRightTruncation:
+		return apperr.New(apperr.ErrInvalidInput, fmt.Sprintf("invalid %s input", resource), err)
+	}
+	return err
+}
+
+// Retryable reports whether a Postgres error is likely to go away, such as the server
+// still starting up or the network being unavailable, as opposed to bad credentials or a missing
+// database which no amount of retrying will fix. Timeouts are retryable: a per-attempt timeout
+// such as DB_CONNECT_TIMEOUT wraps context.DeadlineExceeded while the database starts, and
+// retry.Do already stops once the caller's context is done.
+func Retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		// Without a response from the server the failure is in reaching it, e.g. connection
+		// refused or DNS not resolving while the database container starts
+		return true
+	}
+
+	switch pgErr.Code {
+	case "57P01", // admin_shutdown
+		"57P02", // crash_shutdown
+		"57P03", // cannot_connect_now, the server is starting up or in recovery
+		"53300", // too_many_connections
+		"53400", // configuration_limit_exceeded
+		"40001", // serialization_failure
+		"40P01": // deadlock_detected
+		return true
+	}
+	// Class 08 is connection exceptions, other classes include invalid credentials (28) and an
+	// unknown database (3D000)
+	return pgErr.Code[:2] == "08"
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/types/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// idempotencyLockTimeout is how long a key stays claimed by a request which never completed, e.g.
+// because the instance handling it crashed. It is longer than the slowest route accepting keys.
+const idempotencyLockTimeout = time.Minute
+
+// IdempotencyKeys stores idempotency keys with the response to the first request made with each,
+// until they expire after the TTL
+type IdempotencyKeys struct {
+	db  Querier
+	ttl time.Duration
+}
+
+func NewIdempotencyKeys(db Querier, ttl time.Duration) *IdempotencyKeys {
+	return &IdempotencyKeys{db: db, ttl: ttl}
+}
+
+// Claim records the key for a new request with the fingerprint and reports true, or returns the
+// key's record and false when an unexpired request has already claimed it. Expired keys, and keys
+// whose request was abandoned without completing, are claimed again.
+func (k *IdempotencyKeys) Claim(ctx context.Context, scope, key, fingerprint string) (models.IdempotencyKey, bool, error) {
+	claim := `
+		INSERT INTO idempotency_keys (scope, key, fingerprint, expires_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP + make_interval(secs => $4))
+		ON CONFLICT (scope, key) DO UPDATE
+		SET fingerprint = excluded.fingerprint, status_code = NULL, header = NULL, body = NULL,
+			created_at = CURRENT_TIMESTAMP, expires_at = excluded.expires_at
+		WHERE idempotency_keys.expires_at <= CURRENT_TIMESTAMP
+			OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at <= CURRENT_TIMESTAMP - make_interval(secs => $5))
+		RETURNING scope, key, fingerprint, created_at, expires_at`
+	existing := `
+		SELECT scope, key, fingerprint, status_code, header, body, created_at, expires_at
+		FROM idempotency_keys WHERE scope = $1 AND key = $2`
+
+	// The existing key may expire and be deleted between the two queries, the second attempt
+	// then claims it
+	for range 2 {
+		var record models.IdempotencyKey
+		err := k.db.QueryRow(ctx, claim, scope, key, fingerprint, k.ttl.Seconds(), idempotencyLockTimeout.Seconds()).
+			Scan(&record.Scope, &record.Key, &record.Fingerprint, &record.CreatedAt, &record.ExpiresAt)
+		if err == nil {
+			return record, true, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return models.IdempotencyKey{}, false, TranslateError(fmt.Errorf("failed to claim idempotency key: %w", err), "idempotency key")
+		}
+
+		err = k.db.QueryRow(ctx, existing, scope, key).
+			Scan(&record.Scope, &record.Key, &record.Fingerprint, &record.StatusCode, &record.Header, &record.Body, &record.CreatedAt, &record.ExpiresAt)
+		if err == nil {
+			return record, false, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return models.IdempotencyKey{}, false, fmt.Errorf("failed to get idempotency key: %w", err)
+		}
+	}
+	return models.IdempotencyKey{}, false, fmt.Errorf("failed to claim idempotency key %q: deleted while claiming", key)
+}
+
+// ErrIdempotencyClaimLost is returned when completing or releasing a claim which has since been
+// taken over by another request, e.g. because this one ran for longer than idempotencyLockTimeout
+var ErrIdempotencyClaimLost = errors.New("idempotency key claim lost")
+
+// Complete stores the response to the request which made the claim, to be replayed for repeats. A
+// claim is identified by when it was made, so a request can't overwrite a newer claim of its key.
+func (k *IdempotencyKeys) Complete(ctx context.Context, claim models.IdempotencyKey, statusCode int, header http.Header, body []byte) error {
+	query := `
+		UPDATE idempotency_keys SET status_code = $4, header = $5, body = $6
+		WHERE scope = $1 AND key = $2 AND created_at = $3 AND status_code IS NULL`
+	result, err := k.db.Exec(ctx, query, claim.Scope, claim.Key, claim.CreatedAt, statusCode, header, body)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("failed to store idempotent response for key %q: %w", claim.Key, ErrIdempotencyClaimLost)
+	}
+	return nil
+}
+
+// Release deletes the claim of a request which failed without a response worth replaying, so that
+// a retry runs the request again
+func (k *IdempotencyKeys) Release(ctx context.Context, claim models.IdempotencyKey) error {
+	query := "DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND created_at = $3 AND status_code IS NULL"
+	result, err := k.db.Exec(ctx, query, claim.Scope, claim.Key, claim.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("failed to release idempotency key %q: %w", claim.Key, ErrIdempotencyClaimLost)
+	}
+	return nil
+}
+
+// DeleteExpired deletes the keys past their TTL and returns how many were deleted
+func (k *IdempotencyKeys) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := k.db.Exec(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// Cleanup deletes expired keys every interval until ctx is cancelled. Expired keys are never
+// replayed, deleting them only keeps the table small.
+func (k *IdempotencyKeys) Cleanup(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		deleted, err := k.DeleteExpired(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("Failed to clean up idempotency keys", "error", err)
+			}
+			continue
+		}
+		if deleted > 0 {
+			slog.Info("Deleted expired idempotency keys", "count", deleted)
+		}
+	}
+}
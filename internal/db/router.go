@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Querier runs queries, it is implemented by *pgxpool.Pool, *pgx.Conn and pgx.Tx so the same
+// query functions work against either pool and inside transactions
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// TxBeginner starts transactions, it is implemented by *pgxpool.Pool and pgx.Tx
+type TxBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Router sends read-only queries to a read replica while it is healthy and close enough to the
+// primary, and everything else to the primary. Without a replica all queries use the primary.
+type Router struct {
+	primary *pgxpool.Pool
+	replica *pgxpool.Pool
+	maxLag  time.Duration
+	// replicaUsable is updated by Watch, the replica isn't used until it has been checked once
+	replicaUsable atomic.Bool
+}
+
+// NewRouter creates a router, replica may be nil
+func NewRouter(primary, replica *pgxpool.Pool, maxLag time.Duration) *Router {
+	return &Router{primary: primary, replica: replica, maxLag: maxLag}
+}
+
+// Primary returns the primary pool, used for writes and for reads which must see earlier writes
+func (r *Router) Primary() *pgxpool.Pool {
+	return r.primary
+}
+
+// Reader returns the pool for read-only queries which can tolerate replication lag
+func (r *Router) Reader() Querier {
+	if r.replica != nil && r.replicaUsable.Load() {
+		return r.replica
+	}
+	return r.primary
+}
+
+// Watch checks the replica's health and lag every interval until ctx is cancelled
+func (r *Router) Watch(ctx context.Context, interval time.Duration) {
+	if r.replica == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r.checkReplica(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Router) checkReplica(ctx context.Context) {
+	checkCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	lag, err := r.replicationLag(checkCtx)
+	usable := err == nil && lag <= r.maxLag
+	if r.replicaUsable.Swap(usable) == usable || ctx.Err() != nil {
+		return
+	}
+
+	if usable {
+		slog.Info("Routing reads to the replica", "lag", lag.String())
+	} else if err != nil {
+		slog.Warn("Replica unhealthy, routing reads to the primary", "error", err)
+	} else {
+		slog.Warn("Replica lagging, routing reads to the primary", "lag", lag.String(), "max_lag", r.maxLag.String())
+	}
+}
+
+// replicationLag returns how far the replica's replayed data is behind the primary. A replica
+// which has replayed everything the primary had written when the check started isn't lagging, even
+// if the last transaction it replayed is old because nothing has been written since. One which
+// hasn't, e.g. because its WAL receiver has lost the primary, is as far behind as the last
+// transaction it replayed.
+func (r *Router) replicationLag(ctx context.Context) (time.Duration, error) {
+	var primaryLSN string
+	if err := r.primary.QueryRow(ctx, "SELECT pg_current_wal_lsn()::text").Scan(&primaryLSN); err != nil {
+		return 0, fmt.Errorf("failed to get the primary's WAL position: %w", err)
+	}
+
+	var seconds *float64
+	err := r.replica.QueryRow(ctx, `
+		SELECT CASE
+			WHEN NOT pg_is_in_recovery() OR pg_last_wal_replay_lsn() >= $1::pg_lsn THEN 0
+			ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+		END::float8`, primaryLSN,
+	).Scan(&seconds)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check replication lag: %w", err)
+	}
+	if seconds == nil {
+		return 0, errors.New("replica is behind the primary and hasn't replayed a transaction yet")
+	}
+	return time.Duration(*seconds * float64(time.Second)), nil
+}
@@ -3,19 +3,22 @@ package db
 import (
 	"context"
 	"fmt"
+	"iter"
 	"log/slog"
 
 	"github.com/anishsharma21/go-web-dev-template/internal/types/models"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func AddUser(ctx context.Context, dbPool *pgxpool.Pool, user models.User) error {
-	query := "INSERT INTO users (clerk_id) VALUES ($1)"
+// userColumns lists the columns scanned into models.User, in field order
+const userColumns = "id, clerk_id, email, first_name, last_name, created_at, updated_at"
 
-	ct, err := dbPool.Exec(ctx, query, user.ClerkID)
+func AddUser(ctx context.Context, db Querier, user models.User) error {
+	query := "INSERT INTO users (clerk_id, email, first_name, last_name) VALUES ($1, $2, $3, $4)"
+
+	ct, err := db.Exec(ctx, query, user.ClerkID, user.Email, user.FirstName, user.LastName)
 	if err != nil {
-		return fmt.Errorf("failed to insert user: %w", err)
+		return TranslateError(fmt.Errorf("failed to insert user: %w", err), "user")
 	}
 
 	if ct.RowsAffected() != 1 {
@@ -29,25 +32,30 @@ func AddUser(ctx context.Context, dbPool *pgxpool.Pool, user models.User) error
 	return nil
 }
 
-func GetUserByClerkUserId(ctx context.Context, dbPool *pgxpool.Pool, clerkUserId string) (models.User, error) {
-	query := "SELECT * FROM users WHERE clerk_id = $1"
+func GetUserByClerkUserId(ctx context.Context, db Querier, clerkUserId string) (models.User, error) {
+	query := "SELECT " + userColumns + " FROM users WHERE clerk_id = $1"
 
-	row := dbPool.QueryRow(ctx, query, clerkUserId)
+	row := db.QueryRow(ctx, query, clerkUserId)
 
 	var user models.User
-	if err := row.Scan(&user.ID, &user.ClerkID, &user.CreatedAt); err != nil {
-		if err == pgx.ErrNoRows {
-			return models.User{}, err
-		}
-		return models.User{}, fmt.Errorf("error retrieving user with clerk_id %q: %w", clerkUserId, err)
+	if err := row.Scan(&user.ID, &user.ClerkID, &user.Email, &user.FirstName, &user.LastName, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		return models.User{}, TranslateError(fmt.Errorf("error retrieving user with clerk_id %q: %w", clerkUserId, err), "user")
 	}
 	return user, nil
 }
 
-func GetUsers(ctx context.Context, dbPool *pgxpool.Pool) ([]models.User, error) {
-	query := "SELECT * FROM users"
+// GetUsers returns up to limit users with IDs greater than after, ordered by ID, so that the last
+// ID of one page is the cursor for the next. A limit of 0 returns every remaining user. It is
+// read-only, so it can be given the Router's Reader to query a replica.
+func GetUsers(ctx context.Context, db Querier, after int64, limit int) ([]models.User, error) {
+	// LIMIT NULL is the same as no limit
+	var limitArg *int
+	if limit > 0 {
+		limitArg = &limit
+	}
+	query := "SELECT " + userColumns + " FROM users WHERE id > $1 ORDER BY id LIMIT $2"
 
-	rows, err := dbPool.Query(ctx, query)
+	rows, err := db.Query(ctx, query, after, limitArg)
 	if err != nil {
 		return nil, fmt.Errorf("error retrieving users: %w", err)
 	}
@@ -62,17 +70,63 @@ func GetUsers(ctx context.Context, dbPool *pgxpool.Pool) ([]models.User, error)
 	return users, nil
 }
 
-func DeleteUserByID(ctx context.Context, dbPool *pgxpool.Pool, id string) error {
-	query := "DELETE FROM users WHERE id = $1"
+// StreamUsers iterates over the users with IDs greater than after, ordered by ID, reading each row
+// as it is consumed so that exporting the whole table uses constant memory. A limit of 0 returns
+// every remaining user. The query runs until iteration finishes, so consume it promptly.
+func StreamUsers(ctx context.Context, db Querier, after int64, limit int) iter.Seq2[models.User, error] {
+	return func(yield func(models.User, error) bool) {
+		// LIMIT NULL is the same as no limit
+		var limitArg *int
+		if limit > 0 {
+			limitArg = &limit
+		}
 
-	result, err := dbPool.Exec(ctx, query, id)
-	if err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
+		query := "SELECT " + userColumns + " FROM users WHERE id > $1 ORDER BY id LIMIT $2"
+		rows, err := db.Query(ctx, query, after, limitArg)
+		if err != nil {
+			yield(models.User{}, fmt.Errorf("error retrieving users: %w", err))
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			user, err := pgx.RowToStructByNameLax[models.User](rows)
+			if err != nil {
+				yield(models.User{}, fmt.Errorf("error scanning user: %w", err))
+				return
+			}
+			if !yield(user, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(models.User{}, fmt.Errorf("error streaming users: %w", err))
+		}
 	}
+}
 
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("failed to delete user (no row affected): %w", err)
-	}
+// DeleteUserByID deletes the user after check, if given, approves its current version, e.g. by
+// comparing it with the request's If-Match header. The row is locked while checking so that it
+// can't change between the check and the delete.
+func DeleteUserByID(ctx context.Context, db TxBeginner, id string, check func(models.User) error) error {
+	return pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		query := "SELECT " + userColumns + " FROM users WHERE id = $1 FOR UPDATE"
+
+		var user models.User
+		err := tx.QueryRow(ctx, query, id).Scan(&user.ID, &user.ClerkID, &user.Email, &user.FirstName, &user.LastName, &user.CreatedAt, &user.UpdatedAt)
+		if err != nil {
+			return TranslateError(fmt.Errorf("failed to find user to delete: %w", err), "user")
+		}
 
-	return nil
+		if check != nil {
+			if err := check(user); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec(ctx, "DELETE FROM users WHERE id = $1", user.ID); err != nil {
+			return TranslateError(fmt.Errorf("failed to delete user: %w", err), "user")
+		}
+		return nil
+	})
 }
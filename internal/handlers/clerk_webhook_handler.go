@@ -1,63 +1,60 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
-	"os"
 
-	"github.com/anishsharma21/go-web-dev-template/internal"
+	"github.com/anishsharma21/go-web-dev-template/internal/apperr"
 	"github.com/anishsharma21/go-web-dev-template/internal/db"
+	"github.com/anishsharma21/go-web-dev-template/internal/respond"
 	"github.com/anishsharma21/go-web-dev-template/internal/types/models"
+	"github.com/anishsharma21/go-web-dev-template/internal/types/requests"
 	"github.com/jackc/pgx/v5/pgxpool"
-	svix "github.com/svix/svix-webhooks/go"
 )
 
 // ClerkUserCreated represents the user.created event payload
 type ClerkUserCreated struct {
-	ID             string `json:"id"`
-	Object         string `json:"object"`
-	FirstName      string `json:"first_name"`
-	LastName       string `json:"last_name"`
-	EmailAddresses []struct {
+	ID                    string `json:"id"`
+	Object                string `json:"object"`
+	FirstName             string `json:"first_name"`
+	LastName              string `json:"last_name"`
+	PrimaryEmailAddressID string `json:"primary_email_address_id"`
+	EmailAddresses        []struct {
 		ID           string `json:"id"`
 		EmailAddress string `json:"email_address"`
 	} `json:"email_addresses"`
 	// Add other fields as needed
 }
 
-var WEBHOOK_SECRET string
-
-func init() {
-	WEBHOOK_SECRET = os.Getenv(internal.CLERK_WEBHOOK_SIGNING_SECRET)
-	if WEBHOOK_SECRET == "" && internal.ENVIRONMENT == "production" {
-		slog.ErrorContext(context.Background(), "CLERK_WEBHOOK_SIGNING_SECRET not set")
-		os.Exit(1)
-	} else if WEBHOOK_SECRET == "" && internal.ENVIRONMENT == "development" {
-		slog.WarnContext(context.Background(), "CLERK_WEBHOOK_SIGNING_SECRET not set")
+func (u ClerkUserCreated) primaryEmailAddress() *string {
+	for _, emailAddress := range u.EmailAddresses {
+		if emailAddress.ID == u.PrimaryEmailAddressID {
+			return &emailAddress.EmailAddress
+		}
 	}
+	return nil
 }
 
 // ClerkWebhookHandler handles webhook events from Clerk
-func ClerkWebhookHandler(dbPool *pgxpool.Pool) http.HandlerFunc {
+func ClerkWebhookHandler(dbPool *pgxpool.Pool, verifier *WebhookVerifier) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
 		// Read the request body
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				slog.LogAttrs(ctx, slog.LevelWarn, "Request body too large", slog.Int64("max_bytes", maxBytesErr.Limit))
+				respond.Problemf(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit))
+				return
+			}
 			slog.LogAttrs(ctx, slog.LevelError, "Failed to read request body", slog.String("error", err.Error()))
-			http.Error(w, "Failed to read request body", http.StatusBadRequest)
-			return
-		}
-
-		// Initialize the Svix webhook verifier
-		wh, err := svix.NewWebhook(WEBHOOK_SECRET)
-		if err != nil {
-			slog.LogAttrs(ctx, slog.LevelError, "Failed to initialize webhook verifier", slog.String("error", err.Error()))
-			http.Error(w, "Server error", http.StatusInternalServerError)
+			respond.Problemf(w, r, http.StatusBadRequest, "Failed to read request body")
 			return
 		}
 
@@ -68,39 +65,43 @@ func ClerkWebhookHandler(dbPool *pgxpool.Pool) http.HandlerFunc {
 				headers.Set(header, value)
 			} else {
 				slog.LogAttrs(ctx, slog.LevelError, "Missing required header", slog.String("header", header))
-				http.Error(w, "Missing required headers", http.StatusBadRequest)
+				respond.Problemf(w, r, http.StatusBadRequest, "Missing required header "+header)
 				return
 			}
 		}
 
-		// Verify the webhook
-		err = wh.Verify(body, headers)
+		// Verify the webhook against each active signing secret
+		secretFingerprint, err := verifier.Verify(body, headers)
+		if errors.Is(err, ErrNoWebhookSecrets) {
+			slog.LogAttrs(ctx, slog.LevelError, "Failed to verify webhook", slog.String("error", err.Error()))
+			respond.Problemf(w, r, http.StatusInternalServerError, "Webhook verification is not configured")
+			return
+		}
 		if err != nil {
 			slog.LogAttrs(ctx, slog.LevelError, "Invalid webhook signature", slog.String("error", err.Error()))
-			http.Error(w, "Invalid webhook signature", http.StatusUnauthorized)
+			respond.Problemf(w, r, http.StatusUnauthorized, "Invalid webhook signature")
 			return
 		}
 
 		// Parse the webhook payload
-		var payload struct {
-			Type string          `json:"type"`
-			Data json.RawMessage `json:"data"`
-		}
+		var payload requests.ClerkWebhookEvent
 		if err := json.Unmarshal(body, &payload); err != nil {
 			slog.LogAttrs(ctx, slog.LevelError, "Failed to parse webhook payload", slog.String("error", err.Error()))
-			http.Error(w, "Failed to parse webhook payload", http.StatusBadRequest)
+			respond.Problemf(w, r, http.StatusBadRequest, "Failed to parse webhook payload")
 			return
 		}
 
 		// Handle different event types
-		slog.LogAttrs(ctx, slog.LevelInfo, "Received verified webhook", slog.String("event_type", payload.Type))
+		slog.LogAttrs(ctx, slog.LevelInfo, "Received verified webhook",
+			slog.String("event_type", payload.Type),
+			slog.String("secret_fingerprint", secretFingerprint))
 
 		switch payload.Type {
 		case "user.created":
 			var userData ClerkUserCreated
 			if err := json.Unmarshal(payload.Data, &userData); err != nil {
 				slog.LogAttrs(ctx, slog.LevelError, "Failed to parse user data", slog.String("error", err.Error()))
-				http.Error(w, "Failed to parse user data", http.StatusBadRequest)
+				respond.Problemf(w, r, http.StatusBadRequest, "Failed to parse user data")
 				return
 			}
 
@@ -111,22 +112,30 @@ func ClerkWebhookHandler(dbPool *pgxpool.Pool) http.HandlerFunc {
 
 			// Create a new user model from the webhook data
 			user := models.User{
-				ClerkID: userData.ID,
+				ClerkID:   userData.ID,
+				Email:     userData.primaryEmailAddress(),
+				FirstName: &userData.FirstName,
+				LastName:  &userData.LastName,
 				// CreatedAt is handled by the database default value
 			}
 
 			// Add the user to the database
-			if err := db.AddUser(ctx, dbPool, user); err != nil {
+			switch err := db.AddUser(ctx, dbPool, user); {
+			case errors.Is(err, apperr.ErrConflict):
+				// Svix retries deliveries that weren't acknowledged, so the user may already exist
+				slog.LogAttrs(ctx, slog.LevelInfo, "User already exists, ignoring repeated event",
+					slog.String("clerk_id", userData.ID))
+			case err != nil:
 				slog.LogAttrs(ctx, slog.LevelError, "Failed to add user to database",
 					slog.String("error", err.Error()),
 					slog.String("clerk_id", userData.ID))
-				http.Error(w, "Failed to process user data", http.StatusInternalServerError)
+				respond.Problemf(w, r, http.StatusInternalServerError, "Failed to process user data")
 				return
+			default:
+				slog.LogAttrs(ctx, slog.LevelInfo, "Successfully added user to database",
+					slog.String("clerk_id", userData.ID))
 			}
 
-			slog.LogAttrs(ctx, slog.LevelInfo, "Successfully added user to database",
-				slog.String("clerk_id", userData.ID))
-
 		// Handle other event types as needed
 		default:
 			slog.LogAttrs(ctx, slog.LevelInfo, "Unhandled event type", slog.String("type", payload.Type))
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/health"
+)
+
+// Healthz reports that the process is alive. It has no dependencies so that a database outage
+// doesn't cause the orchestrator to restart healthy processes.
+func Healthz() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, r, http.StatusOK, health.Report{Status: health.StatusOK})
+	})
+}
+
+// Startupz reports whether startup work such as migrations has completed
+func Startupz(checker *health.Checker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checker.Started() {
+			writeHealthReport(w, r, http.StatusServiceUnavailable, health.Report{Status: health.StatusStarting})
+			return
+		}
+		writeHealthReport(w, r, http.StatusOK, health.Report{Status: health.StatusOK})
+	})
+}
+
+// Readyz reports whether the service can handle traffic, with the result of each check
+func Readyz(checker *health.Checker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := checker.Readiness(r.Context())
+
+		statusCode := http.StatusOK
+		if report.Status != health.StatusOK {
+			slog.WarnContext(r.Context(), "Readiness check failed", "status", report.Status, "checks", report.Checks)
+			statusCode = http.StatusServiceUnavailable
+		}
+		writeHealthReport(w, r, statusCode, report)
+	})
+}
+
+func writeHealthReport(w http.ResponseWriter, r *http.Request, statusCode int, report health.Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to encode health report", "error", err)
+	}
+}
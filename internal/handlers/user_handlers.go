@@ -1,38 +1,43 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
+	"github.com/anishsharma21/go-web-dev-template/internal"
 	"github.com/anishsharma21/go-web-dev-template/internal/db"
+	"github.com/anishsharma21/go-web-dev-template/internal/respond"
 	"github.com/anishsharma21/go-web-dev-template/internal/types/models"
-	"github.com/jackc/pgx/v5"
+	"github.com/anishsharma21/go-web-dev-template/internal/types/requests"
+	"github.com/anishsharma21/go-web-dev-template/internal/validate"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 func AddNewUser(dbPool *pgxpool.Pool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var ctx = r.Context()
-		var user models.User
+		var req requests.SignUpRequest
 
-		if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-			slog.Error("Failed to decode request body", "error", err)
-			http.Error(w, "Bad request", http.StatusBadRequest)
+		if err := validate.DecodeJSON(r, &req); err != nil {
+			slog.Warn("Invalid sign up request", "error", err)
+			respond.Error(w, r, err)
 			return
 		}
 
-		query := "INSERT INTO users (clerk_id) VALUES ($1)"
-		result, err := dbPool.Exec(ctx, query, user.ClerkID)
-		if err != nil {
-			slog.Error("Failed to insert user", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
+		user := models.User{
+			ClerkID:   req.ClerkID,
+			Email:     req.Email,
+			FirstName: req.FirstName,
+			LastName:  req.LastName,
 		}
-
-		if result.RowsAffected() == 0 {
-			slog.Error("No rows affected")
-			http.Error(w, "User not created", http.StatusInternalServerError)
+		if err := db.AddUser(ctx, dbPool, user); err != nil {
+			respond.Error(w, r, err)
 			return
 		}
 
@@ -40,72 +45,155 @@ func AddNewUser(dbPool *pgxpool.Pool) http.Handler {
 	})
 }
 
-func GetUsers(dbPool *pgxpool.Pool) http.Handler {
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+// GetUsers lists users as JSON. Callers written before pagination was added expect every user,
+// so pages are only returned when asked for with ?limit= or ?after=. The next page is then linked
+// in the Link header with an ?after= cursor, which is absent on the last page. NDJSON and CSV,
+// selected with the Accept header or ?format=, are for exports: every remaining user is streamed
+// from the database unless ?limit= is set. Exports, and JSON lists of every user, may take up to
+// exportTimeout.
+func GetUsers(router *db.Router, timeout, exportTimeout time.Duration) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var ctx = r.Context()
-		query := "SELECT id, clerk_id, created_at FROM users"
+		w.Header().Add("Vary", "Accept")
+		format, err := respond.Negotiate(r, respond.JSON, respond.NDJSON, respond.CSV)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		query := r.URL.Query()
+		paginated := query.Has("limit") || query.Has("after")
+		// Exports and unpaginated JSON lists are streamed, so memory use doesn't grow with the table
+		if format != respond.JSON || !paginated {
+			after, limit, err := pageParams(r, 0, 0)
+			if err != nil {
+				respond.Error(w, r, err)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), exportTimeout)
+			defer cancel()
+			// Exports can outlast the server's write timeout
+			if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(exportTimeout)); err != nil {
+				slog.WarnContext(ctx, "Failed to extend write deadline for export", "error", err)
+			}
+			if format == respond.CSV {
+				w.Header().Set("Content-Disposition", `attachment; filename="users.csv"`)
+			}
+			respond.StreamList(w, r.WithContext(ctx), format, db.StreamUsers(ctx, router.Reader(), after, limit))
+			return
+		}
 
-		rows, err := dbPool.Query(ctx, query)
+		after, limit, err := pageParams(r, defaultPageSize, maxPageSize)
 		if err != nil {
-			slog.Error("Failed to query users", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
-		defer rows.Close()
 
-		var users []models.User
-		users, err = pgx.CollectRows[models.User](rows, pgx.RowToStructByNameLax[models.User])
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		users, err := db.GetUsers(ctx, router.Reader(), after, limit)
 		if err != nil {
-			slog.Error("Failed to collect rows", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			respond.Error(w, r, fmt.Errorf("failed to get users: %w", err))
 			return
 		}
+		if users == nil {
+			users = []models.User{}
+		}
+
+		if len(users) == limit {
+			next := url.URL{Path: r.URL.Path, RawQuery: url.Values{
+				"after": {strconv.Itoa(users[len(users)-1].ID)},
+				"limit": {strconv.Itoa(limit)},
+			}.Encode()}
+			// Added rather than set, a deprecated API version has already linked its migration guide
+			w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		err = json.NewEncoder(w).Encode(users)
 		if err != nil {
+			// The status line has already been sent, all that's left is to log it
 			slog.Error("Failed to encode users to JSON", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
 		}
 	})
 }
 
-func GetUserByClerkUserId(dbPool *pgxpool.Pool) http.Handler {
+// pageParams reads the ?after= cursor and ?limit= page size, a maxLimit of 0 allows any limit
+func pageParams(r *http.Request, defaultLimit, maxLimit int) (after int64, limit int, err error) {
+	var errs []respond.FieldError
+	query := r.URL.Query()
+
+	limit = defaultLimit
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || (maxLimit > 0 && limit > maxLimit) {
+			message := "must be a positive number"
+			if maxLimit > 0 {
+				message = fmt.Sprintf("must be a number between 1 and %d", maxLimit)
+			}
+			errs = append(errs, respond.FieldError{Field: "limit", Message: message})
+		}
+	}
+	if value := query.Get("after"); value != "" {
+		after, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || after < 0 {
+			errs = append(errs, respond.FieldError{Field: "after", Message: "must be a user ID"})
+		}
+	}
+
+	if len(errs) > 0 {
+		problem := respond.NewProblem(http.StatusBadRequest, "Query has invalid parameters")
+		problem.Errors = errs
+		return 0, 0, problem
+	}
+	return after, limit, nil
+}
+
+func GetUserByClerkUserId(router *db.Router) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		clerkUserId := r.PathValue("clerk_user_id")
 
 		if clerkUserId == "" {
 			slog.Error("Clerk user ID is empty")
-			http.Error(w, "Clerk user ID is required", http.StatusBadRequest)
+			respond.Problemf(w, r, http.StatusBadRequest, "Clerk user ID is required")
 			return
 		}
 
-		user, err := db.GetUserByClerkUserId(ctx, dbPool, clerkUserId)
+		user, err := db.GetUserByClerkUserId(ctx, router.Reader(), clerkUserId)
 		if err != nil {
-			if err == pgx.ErrNoRows {
-				slog.Error("User not found", "clerk_user_id", clerkUserId)
-				http.Error(w, "User not found", http.StatusNotFound)
-				return
-			}
-			slog.Error("Failed to get user by Clerk user ID", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		err = json.NewEncoder(w).Encode(user)
+		writeUser(w, r, user)
+	})
+}
+
+// GetCurrentUser returns the user the session token belongs to
+func GetCurrentUser(router *db.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		clerkUserId, _ := ctx.Value(internal.CLERK_USER_ID_KEY).(string)
+
+		user, err := db.GetUserByClerkUserId(ctx, router.Reader(), clerkUserId)
 		if err != nil {
-			slog.Error("Failed to encode user to JSON", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
+
+		writeUser(w, r, user)
 	})
 }
 
+// DeleteUserByID deletes a user, honouring If-Match and If-Unmodified-Since so that clients don't
+// delete a user that has changed since they fetched it
 func DeleteUserByID(dbPool *pgxpool.Pool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var ctx = r.Context()
@@ -113,17 +201,33 @@ func DeleteUserByID(dbPool *pgxpool.Pool) http.Handler {
 
 		if userID == "" {
 			slog.Error("User ID is empty")
-			http.Error(w, "User ID is required", http.StatusBadRequest)
+			respond.Problemf(w, r, http.StatusBadRequest, "User ID is required")
 			return
 		}
 
-		err := db.DeleteUserByID(ctx, dbPool, userID)
+		err := db.DeleteUserByID(ctx, dbPool, userID, func(user models.User) error {
+			return respond.CheckPreconditions(r, user.ETag(), user.LastModified())
+		})
 		if err != nil {
-			slog.Error("Failed to delete user", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
 		w.WriteHeader(http.StatusNoContent)
 	})
 }
+
+// writeUser writes the user with its ETag and Last-Modified headers, or a 304 if the client's
+// cached copy is current
+func writeUser(w http.ResponseWriter, r *http.Request, user models.User) {
+	if respond.NotModified(w, r, user.ETag(), user.LastModified()) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		// The status line has already been sent, all that's left is to log it
+		slog.Error("Failed to encode user to JSON", "error", err)
+	}
+}
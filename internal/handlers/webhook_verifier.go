@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/secrets"
+	svix "github.com/svix/svix-webhooks/go"
+)
+
+// ErrNoWebhookSecrets is returned when no signing secret is configured
+var ErrNoWebhookSecrets = errors.New("no webhook signing secrets configured")
+
+// WebhookVerifier verifies Svix webhook signatures against a list of active signing secrets, so
+// that deliveries signed with either the old or the new secret are accepted while rotating
+type WebhookVerifier struct {
+	secrets atomic.Pointer[[]signingSecret]
+}
+
+type signingSecret struct {
+	// fingerprint identifies the secret in logs without revealing it
+	fingerprint string
+	webhook     *svix.Webhook
+}
+
+// NewWebhookVerifier creates the verifiers for every secret in the value, which holds one or more
+// secrets separated by commas or whitespace. The verifiers are rebuilt when the value is rotated.
+func NewWebhookVerifier(value *secrets.Value) (*WebhookVerifier, error) {
+	v := &WebhookVerifier{}
+	if err := v.update(value.Get()); err != nil {
+		return nil, err
+	}
+
+	value.OnChange(func(secret string) {
+		if err := v.update(secret); err != nil {
+			slog.Error("Failed to update webhook signing secrets, keeping previous secrets", "error", err)
+			return
+		}
+		slog.Info("Updated webhook signing secrets", "fingerprints", v.fingerprints())
+	})
+
+	return v, nil
+}
+
+func (v *WebhookVerifier) update(value string) error {
+	var signingSecrets []signingSecret
+	for _, secret := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\t' || r == '\r'
+	}) {
+		webhook, err := svix.NewWebhook(secret)
+		if err != nil {
+			return fmt.Errorf("invalid webhook signing secret #%d: %w", len(signingSecrets)+1, err)
+		}
+		sum := sha256.Sum256([]byte(secret))
+		signingSecrets = append(signingSecrets, signingSecret{
+			fingerprint: hex.EncodeToString(sum[:4]),
+			webhook:     webhook,
+		})
+	}
+
+	v.secrets.Store(&signingSecrets)
+	return nil
+}
+
+// Verify checks the payload against each active secret in turn and returns the fingerprint of
+// the secret that matched
+func (v *WebhookVerifier) Verify(payload []byte, headers http.Header) (string, error) {
+	signingSecrets := *v.secrets.Load()
+	if len(signingSecrets) == 0 {
+		return "", ErrNoWebhookSecrets
+	}
+
+	var err error
+	for _, secret := range signingSecrets {
+		if err = secret.webhook.Verify(payload, headers); err == nil {
+			return secret.fingerprint, nil
+		}
+	}
+	return "", err
+}
+
+func (v *WebhookVerifier) fingerprints() []string {
+	var fingerprints []string
+	for _, secret := range *v.secrets.Load() {
+		fingerprints = append(fingerprints, secret.fingerprint)
+	}
+	return fingerprints
+}
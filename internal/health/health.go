@@ -0,0 +1,128 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK           = "ok"
+	StatusFail         = "fail"
+	StatusShuttingDown = "shutting_down"
+	StatusStarting     = "starting"
+)
+
+// Check is a single readiness check, e.g. pinging the database
+type Check struct {
+	Name    string
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+// Result is the outcome of a single check. Errors are logged rather than included, as they can
+// reveal hosts, users and upstream URLs to anyone who can reach the health endpoints.
+type Result struct {
+	Status     string `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Report is the outcome of all readiness checks
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Checker tracks the startup and shutdown state of the process and runs the readiness checks
+type Checker struct {
+	checks       []Check
+	started      atomic.Bool
+	shuttingDown atomic.Bool
+}
+
+func NewChecker(checks ...Check) *Checker {
+	return &Checker{checks: checks}
+}
+
+// MarkStarted is called once startup work such as migrations has completed
+func (c *Checker) MarkStarted() {
+	c.started.Store(true)
+}
+
+func (c *Checker) Started() bool {
+	return c.started.Load()
+}
+
+// StartShutdown makes readiness fail immediately so load balancers stop sending traffic while
+// in-flight requests are drained
+func (c *Checker) StartShutdown() {
+	c.shuttingDown.Store(true)
+}
+
+// Drain starts the shutdown and waits for delay, so that load balancers notice readiness failing
+// and stop sending new requests before the server stops accepting them
+func (c *Checker) Drain(ctx context.Context, delay time.Duration) error {
+	c.StartShutdown()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Readiness runs all checks concurrently, each bounded by its own timeout
+func (c *Checker) Readiness(ctx context.Context) Report {
+	if c.shuttingDown.Load() {
+		return Report{Status: StatusShuttingDown}
+	}
+	if !c.started.Load() {
+		return Report{Status: StatusStarting}
+	}
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(c.checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+func run(ctx context.Context, check Check) Result {
+	if check.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, check.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := check.Run(ctx)
+	result := Result{Status: StatusOK, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		slog.WarnContext(ctx, "Health check failed", "check", check.Name, "error", err, "duration_ms", result.DurationMS)
+		result.Status = StatusFail
+	}
+	return result
+}
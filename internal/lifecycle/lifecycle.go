@@ -0,0 +1,216 @@
+// Package lifecycle starts the application's components in dependency order and stops them in
+// reverse order on shutdown, so e.g. the HTTP server has drained before the database pool closes
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Hook is a component's start and stop functions. Start should return once the component is
+// running, starting any long-running work in a goroutine which Stop ends. The context passed to
+// Start only bounds starting, it is cancelled when startup is interrupted by a signal.
+type Hook struct {
+	Name string
+	// DependsOn names hooks which must have started before this one, and stop after it
+	DependsOn []string
+	Start     func(ctx context.Context) error
+	Stop      func(ctx context.Context) error
+	// StartTimeout bounds Start (0 = no limit)
+	StartTimeout time.Duration
+	// StopTimeout bounds Stop, once it passes the next hook is stopped (0 = the manager's default)
+	StopTimeout time.Duration
+}
+
+// Manager runs a set of hooks
+type Manager struct {
+	stopTimeout time.Duration
+	hooks       []Hook
+	started     []Hook
+
+	shutdownOnce sync.Once
+	shutdown     chan struct{}
+	reason       error
+}
+
+// New creates a manager which gives each hook stopTimeout to stop unless it sets its own
+func New(stopTimeout time.Duration) *Manager {
+	return &Manager{
+		stopTimeout: stopTimeout,
+		shutdown:    make(chan struct{}),
+	}
+}
+
+// Append registers a hook. Hooks without dependencies between them start in the order appended.
+func (m *Manager) Append(hook Hook) {
+	m.hooks = append(m.hooks, hook)
+}
+
+// Shutdown asks Run to stop the application, e.g. when a component fails after starting. The
+// first non-nil reason is returned by Run.
+func (m *Manager) Shutdown(reason error) {
+	m.shutdownOnce.Do(func() {
+		m.reason = reason
+		close(m.shutdown)
+	})
+}
+
+// Run starts every hook, waits for SIGINT or SIGTERM, a call to Shutdown or ctx to be cancelled,
+// then stops the started hooks in reverse order. A second signal exits immediately.
+func (m *Manager) Run(ctx context.Context) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		sig := <-signals
+		slog.Warn("Received signal", "signal", sig.String())
+		m.Shutdown(nil)
+
+		sig = <-signals
+		slog.Error("Received second signal, exiting without waiting for shutdown", "signal", sig.String())
+		os.Exit(1)
+	}()
+
+	// A signal during startup interrupts whichever hook is starting
+	startCtx, cancelStart := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-m.shutdown:
+			cancelStart()
+		case <-startCtx.Done():
+		}
+	}()
+	err := m.Start(startCtx)
+	cancelStart()
+	if err != nil {
+		m.Shutdown(err)
+	}
+
+	select {
+	case <-m.shutdown:
+	case <-ctx.Done():
+		m.Shutdown(nil)
+	}
+
+	return errors.Join(m.reason, m.Stop(context.WithoutCancel(ctx)))
+}
+
+// Start starts the hooks in dependency order, stopping at the first one which fails
+func (m *Manager) Start(ctx context.Context) error {
+	ordered, err := m.order()
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range ordered {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("startup interrupted before %s: %w", hook.Name, err)
+		}
+		if hook.Start != nil {
+			slog.Info("Starting", "component", hook.Name)
+			if err := run(ctx, hook.StartTimeout, hook.Start); err != nil {
+				return fmt.Errorf("failed to start %s: %w", hook.Name, err)
+			}
+		}
+		m.started = append(m.started, hook)
+	}
+	return nil
+}
+
+// Stop stops the started hooks in reverse order, continuing past hooks which fail or time out
+func (m *Manager) Stop(ctx context.Context) error {
+	var errs []error
+	for i := len(m.started) - 1; i >= 0; i-- {
+		hook := m.started[i]
+		if hook.Stop == nil {
+			continue
+		}
+
+		timeout := hook.StopTimeout
+		if timeout == 0 {
+			timeout = m.stopTimeout
+		}
+		slog.Info("Stopping", "component", hook.Name, "timeout", timeout.String())
+		if err := run(ctx, timeout, hook.Stop); err != nil {
+			slog.Error("Failed to stop", "component", hook.Name, "error", err)
+			errs = append(errs, fmt.Errorf("failed to stop %s: %w", hook.Name, err))
+		}
+	}
+	m.started = nil
+	return errors.Join(errs...)
+}
+
+// run calls fn, giving up on it once the timeout passes even if fn ignores its context
+func run(ctx context.Context, timeout time.Duration, fn func(context.Context) error) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// order sorts the hooks so every hook comes after its dependencies, keeping the order they were
+// appended in otherwise
+func (m *Manager) order() ([]Hook, error) {
+	byName := make(map[string]Hook, len(m.hooks))
+	for _, hook := range m.hooks {
+		if _, ok := byName[hook.Name]; ok {
+			return nil, fmt.Errorf("duplicate lifecycle hook %q", hook.Name)
+		}
+		byName[hook.Name] = hook
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(m.hooks))
+	var ordered []Hook
+	var visit func(hook Hook) error
+	visit = func(hook Hook) error {
+		switch state[hook.Name] {
+		case visiting:
+			return fmt.Errorf("lifecycle hook %q depends on itself", hook.Name)
+		case visited:
+			return nil
+		}
+		state[hook.Name] = visiting
+		for _, name := range hook.DependsOn {
+			dependency, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("lifecycle hook %q depends on unknown hook %q", hook.Name, name)
+			}
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		state[hook.Name] = visited
+		ordered = append(ordered, hook)
+		return nil
+	}
+
+	for _, hook := range m.hooks {
+		if err := visit(hook); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
@@ -4,51 +4,60 @@ import (
 	"context"
 	"log/slog"
 	"net/http"
-	"os"
 
 	"github.com/anishsharma21/go-web-dev-template/internal"
+	"github.com/anishsharma21/go-web-dev-template/internal/respond"
 	"github.com/clerk/clerk-sdk-go/v2"
 	clerkhttp "github.com/clerk/clerk-sdk-go/v2/http"
+	"github.com/clerk/clerk-sdk-go/v2/jwks"
 )
 
-func init() {
-	apiKey := os.Getenv(internal.CLERK_SECRET_KEY)
-	if apiKey == "" {
-		slog.Error("CLERK_SECRET_KEY not set")
-		os.Exit(1)
-	}
-	clerk.SetKey(apiKey)
+// NewJWKSClient creates the client used to fetch the keys that session tokens are verified against
+func NewJWKSClient(secretKey string) *jwks.Client {
+	return jwks.NewClient(&clerk.ClientConfig{
+		BackendConfig: clerk.BackendConfig{Key: clerk.String(secretKey)},
+	})
 }
 
-// ClerkAuthMiddleware verifies JWT tokens and adds user ID to context
-func ClerkAuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
-
-		validateHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			claims, ok := clerk.SessionClaimsFromContext(r.Context())
-			if !ok {
-				slog.ErrorContext(ctx, "Failed to get session claims")
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Extract user ID from claims
-			userID := claims.Subject
-			if userID == "" {
-				slog.ErrorContext(ctx, "User ID not found in claims")
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Add user ID to the existing context (preserving request ID)
-			ctx := context.WithValue(r.Context(), internal.CLERK_USER_ID_KEY, userID)
-
-			next.ServeHTTP(w, r.WithContext(ctx))
+// ClerkAuthMiddleware verifies JWT tokens and adds user ID to context. Missing or invalid tokens
+// are answered with a 401 problem, the same as any other error response.
+func ClerkAuthMiddleware(jwksClient *jwks.Client) func(http.Handler) http.Handler {
+	clerkMiddleware := clerkhttp.RequireHeaderAuthorization(
+		clerkhttp.JWKSClient(jwksClient),
+		clerkhttp.AuthorizationFailureHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			slog.WarnContext(r.Context(), "Missing or invalid session token")
+			respond.Problemf(w, r, http.StatusUnauthorized, "A valid session token is required")
+		})),
+	)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			validateHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				claims, ok := clerk.SessionClaimsFromContext(r.Context())
+				if !ok {
+					slog.ErrorContext(ctx, "Failed to get session claims")
+					respond.Problemf(w, r, http.StatusUnauthorized, "A valid session token is required")
+					return
+				}
+
+				// Extract user ID from claims
+				userID := claims.Subject
+				if userID == "" {
+					slog.ErrorContext(ctx, "User ID not found in claims")
+					respond.Problemf(w, r, http.StatusUnauthorized, "A valid session token is required")
+					return
+				}
+
+				// Add user ID to the existing context (preserving request ID)
+				ctx := context.WithValue(r.Context(), internal.CLERK_USER_ID_KEY, userID)
+
+				next.ServeHTTP(w, r.WithContext(ctx))
+			})
+
+			clerkHandler := clerkMiddleware(validateHandler)
+			clerkHandler.ServeHTTP(w, r)
 		})
-
-		clerkMiddleware := clerkhttp.RequireHeaderAuthorization()
-		clerkHandler := clerkMiddleware(validateHandler)
-		clerkHandler.ServeHTTP(w, r)
-	})
+	}
 }
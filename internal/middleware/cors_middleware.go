@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures which cross-origin requests are allowed
+type CORSOptions struct {
+	// AllowedOrigins may contain exact origins ("https://app.example.com"),
+	// wildcard subdomains ("https://*.example.com") or "*" to allow any origin.
+	// "*" is ignored when AllowCredentials is set, so credentialed requests are
+	// only allowed from origins that are listed explicitly.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// CORSMiddleware adds CORS headers to responses and answers preflight requests.
+// It must wrap the whole mux so that preflight requests are answered before
+// routing and authentication, which would otherwise reject them.
+func CORSMiddleware(opts CORSOptions) func(http.Handler) http.Handler {
+	allowedMethods := strings.Join(opts.AllowedMethods, ", ")
+	allowedHeaders := strings.Join(opts.AllowedHeaders, ", ")
+	exposedHeaders := strings.Join(opts.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(opts.MaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			header := w.Header()
+			header.Add("Vary", "Origin")
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				header.Add("Vary", "Access-Control-Request-Method")
+				header.Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			allowed := opts.originAllowed(origin)
+			if allowed {
+				if opts.allowsAnyOrigin() && !opts.AllowCredentials {
+					header.Set("Access-Control-Allow-Origin", "*")
+				} else {
+					header.Set("Access-Control-Allow-Origin", origin)
+				}
+				if opts.AllowCredentials {
+					header.Set("Access-Control-Allow-Credentials", "true")
+				}
+			}
+
+			if !preflight {
+				if allowed && exposedHeaders != "" {
+					header.Set("Access-Control-Expose-Headers", exposedHeaders)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Preflight requests never reach the route handlers. Disallowed origins
+			// get an empty response without CORS headers so the browser blocks them.
+			if allowed {
+				header.Set("Access-Control-Allow-Methods", allowedMethods)
+				if allowedHeaders != "" {
+					header.Set("Access-Control-Allow-Headers", allowedHeaders)
+				}
+				if opts.MaxAge > 0 {
+					header.Set("Access-Control-Max-Age", maxAge)
+				}
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+func (opts CORSOptions) allowsAnyOrigin() bool {
+	for _, allowed := range opts.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (opts CORSOptions) originAllowed(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range opts.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" && !opts.AllowCredentials || allowed == origin {
+			return true
+		}
+
+		// Wildcard subdomains, e.g. https://*.example.com matches https://app.example.com
+		// but not https://example.com or https://evil-example.com
+		scheme, host, found := strings.Cut(allowed, "://*.")
+		if !found {
+			continue
+		}
+		prefix := scheme + "://"
+		suffix := "." + host
+		if strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			subdomain := strings.TrimSuffix(strings.TrimPrefix(origin, prefix), suffix)
+			if subdomain != "" && !strings.ContainsAny(subdomain, "/:@") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal"
+	"github.com/anishsharma21/go-web-dev-template/internal/respond"
+	"github.com/anishsharma21/go-web-dev-template/internal/types/models"
+)
+
+// maxIdempotencyKeyLength matches the idempotency_keys.key column
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyKeyInUseProblem is the problem type of the 409 for a repeat of a request which is still
+// running, which tells clients to retry it rather than treat it as a conflict with existing data
+const IdempotencyKeyInUseProblem = "/problems/idempotency-key-in-use"
+
+// IdempotencyStore keeps the first request made with each idempotency key and its response, it is
+// implemented by *db.IdempotencyKeys
+type IdempotencyStore interface {
+	Claim(ctx context.Context, scope, key, fingerprint string) (models.IdempotencyKey, bool, error)
+	Complete(ctx context.Context, claim models.IdempotencyKey, statusCode int, header http.Header, body []byte) error
+	Release(ctx context.Context, claim models.IdempotencyKey) error
+}
+
+// IdempotencyMiddleware makes requests with an Idempotency-Key header safe to retry. The first
+// request with a key runs as usual and its response is stored, repeats of it receive the stored
+// response with an Idempotent-Replayed header instead of running again. Reusing a key for a
+// different request is answered with 422, and repeating a request which is still running with
+// 409. Server errors aren't stored, so a retry runs the request again. Keys are scoped to the
+// authenticated user, when there is one, so it must run after authentication. Unauthenticated
+// routes share one scope across all clients, so clients must send unguessable keys, such as
+// random UUIDs, and a key only replays a response for an identical request.
+func IdempotencyMiddleware(store IdempotencyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				respond.Problemf(w, r, http.StatusBadRequest, fmt.Sprintf("Idempotency-Key must not exceed %d characters", maxIdempotencyKeyLength))
+				return
+			}
+			ctx := r.Context()
+
+			// The body is part of the fingerprint, so it's read here and replaced for the handler
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					respond.Problemf(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit))
+					return
+				}
+				respond.Problemf(w, r, http.StatusBadRequest, "Failed to read request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			scope, _ := ctx.Value(internal.CLERK_USER_ID_KEY).(string)
+			fingerprint := requestFingerprint(r, body)
+			record, claimed, err := store.Claim(ctx, scope, key, fingerprint)
+			if err != nil {
+				respond.Error(w, r, err)
+				return
+			}
+			if !claimed {
+				replay(w, r, record, fingerprint)
+				return
+			}
+
+			rw := &recordingWriter{ResponseWriter: w, before: w.Header().Clone()}
+			defer func() {
+				// The request's context may have been cancelled by now, but the key still has to
+				// be completed or released
+				storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+				defer cancel()
+
+				var err error
+				if rw.statusCode != 0 && rw.statusCode < 500 {
+					err = store.Complete(storeCtx, record, rw.statusCode, rw.header, rw.body.Bytes())
+				} else {
+					// The handler failed or panicked, a retry should run it again
+					err = store.Release(storeCtx, record)
+				}
+				if err != nil {
+					slog.ErrorContext(ctx, "Failed to store idempotency key", "error", err)
+				}
+			}()
+			next.ServeHTTP(rw, r)
+			if rw.statusCode == 0 {
+				// Handlers which write nothing respond with 200
+				rw.WriteHeader(http.StatusOK)
+			}
+		})
+	}
+}
+
+// replay answers a repeated request from the key's record
+func replay(w http.ResponseWriter, r *http.Request, record models.IdempotencyKey, fingerprint string) {
+	switch {
+	case record.Fingerprint != fingerprint:
+		respond.Problemf(w, r, http.StatusUnprocessableEntity, "Idempotency-Key has already been used for a different request")
+	case !record.Completed():
+		problem := respond.NewProblem(http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+		problem.Type = IdempotencyKeyInUseProblem
+		w.Header().Set("Retry-After", "1")
+		respond.WriteProblem(w, r, problem)
+	default:
+		slog.InfoContext(r.Context(), "Replaying response for idempotency key", "status", *record.StatusCode)
+		for key, values := range record.Header {
+			w.Header()[key] = values
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(*record.StatusCode)
+		w.Write(record.Body)
+	}
+}
+
+// requestFingerprint identifies a request by its method, URL and body, so that a key reused for a
+// different request can be told apart from a retry
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s\n", r.Method, r.URL.RequestURI())
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// recordingWriter passes the response through while keeping a copy of its status, body and the
+// headers the handler set, which excludes those set by earlier middleware such as the request ID
+type recordingWriter struct {
+	http.ResponseWriter
+	before     http.Header
+	statusCode int
+	header     http.Header
+	body       bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(statusCode int) {
+	if rw.statusCode != 0 || statusCode < 200 {
+		rw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+
+	rw.statusCode = statusCode
+	rw.header = make(http.Header)
+	for key, values := range rw.ResponseWriter.Header() {
+		if !slices.Equal(values, rw.before[key]) {
+			rw.header[key] = slices.Clone(values)
+		}
+	}
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.statusCode == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (rw *recordingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/respond"
+)
+
+// MaxBodyBytesMiddleware limits the size of request bodies. Requests that declare a larger
+// Content-Length are rejected straight away, otherwise reading past the limit fails with an
+// *http.MaxBytesError which handlers should answer with 413.
+func MaxBodyBytesMiddleware(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				slog.WarnContext(r.Context(), "Request body too large", "content_length", r.ContentLength, "max_bytes", maxBytes)
+				respond.Problemf(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body must not exceed %d bytes", maxBytes))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// TimeoutMiddleware cancels the request context after the timeout and responds with 504 if the
+// handler hasn't finished by then. The handler's response is buffered so that a late write can't
+// interleave with the timeout response, which makes it unsuitable for streaming routes.
+func TimeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+
+			tw := &timeoutWriter{ctx: ctx, header: make(http.Header), statusCode: http.StatusOK}
+			done := make(chan struct{})
+			panicChan := make(chan any, 1)
+
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						tw.mu.Lock()
+						defer tw.mu.Unlock()
+						if !tw.timedOut {
+							panicChan <- p
+							return
+						}
+						// The request has already been answered, so the panic can only be logged
+						if p != http.ErrAbortHandler {
+							slog.ErrorContext(ctx, "Handler panicked after the request timed out", "panic", p, "stack", string(debug.Stack()))
+						}
+					}
+				}()
+				next.ServeHTTP(tw, r)
+				tw.mu.Lock()
+				// A handler returning after the deadline, e.g. because it woke up on the cancelled
+				// context, is too late and its response is discarded
+				tw.finished = ctx.Err() == nil
+				tw.mu.Unlock()
+				close(done)
+			}()
+
+			select {
+			case p := <-panicChan:
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.flushTo(w)
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				// The handler may have finished just before the deadline passed, in which case
+				// select can pick either branch and its response is still sent
+				if tw.finished {
+					tw.flushTo(w)
+					return
+				}
+				tw.timedOut = true
+				select {
+				case p := <-panicChan:
+					panic(p)
+				default:
+				}
+				if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					// The client went away, there is nobody left to respond to
+					return
+				}
+				slog.ErrorContext(ctx, "Request timed out", "timeout", timeout.String())
+				respond.Problemf(w, r, http.StatusGatewayTimeout, "Request timed out")
+			}
+		})
+	}
+}
+
+type timeoutWriter struct {
+	ctx         context.Context
+	mu          sync.Mutex
+	header      http.Header
+	body        bytes.Buffer
+	statusCode  int
+	wroteHeader bool
+	timedOut    bool
+	finished    bool
+}
+
+// flushTo writes the buffered response to w, the caller must hold tw.mu
+func (tw *timeoutWriter) flushTo(w http.ResponseWriter) {
+	for key, values := range tw.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(tw.statusCode)
+	w.Write(tw.body.Bytes())
+}
+
+// expired reports whether the deadline has passed, after which writes are discarded, the caller
+// must hold tw.mu
+func (tw *timeoutWriter) expired() bool {
+	return tw.timedOut || tw.ctx.Err() != nil
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.wroteHeader = true
+	return tw.body.Write(b)
+}
+
+func (tw *timeoutWriter) WriteHeader(statusCode int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() || tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	tw.statusCode = statusCode
+}
@@ -21,43 +21,63 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		ctx := context.WithValue(r.Context(), internal.REQUEST_ID_KEY, requestID)
 		r = r.WithContext(ctx)
 
-		// Wrap response writer to capture status code
+		// Wrap response writer to capture status code and response size
 		rw := &responseWriter{
 			ResponseWriter: w,
 			statusCode:     http.StatusOK,
 		}
 
+		// A handler panics with http.ErrAbortHandler to abort a response it can't finish, e.g. a
+		// stream that fails part way, the request is still logged before the server drops the connection
+		defer func() {
+			if p := recover(); p != nil {
+				logRequest(rw, r, start, true)
+				panic(p)
+			}
+		}()
+
 		next.ServeHTTP(rw, r)
 
-		duration := time.Since(start).Milliseconds()
+		logRequest(rw, r, start, false)
+	})
+}
 
-		logLevel := slog.LevelInfo
-		if rw.statusCode >= 400 && rw.statusCode < 500 {
-			logLevel = slog.LevelWarn
-		} else if rw.statusCode >= 500 {
-			logLevel = slog.LevelError
-		}
+func logRequest(rw *responseWriter, r *http.Request, start time.Time, aborted bool) {
+	// Skip logging for favicon requests
+	if strings.Contains(r.URL.Path, "favicon") {
+		return
+	}
 
-		// Skip logging for favicon requests
-		if !strings.Contains(r.URL.Path, "favicon") {
-			slog.Log(ctx, logLevel, fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, rw.statusCode),
-				slog.String("method", r.Method),
-				slog.String("path", r.URL.Path),
-				slog.Int("status_code", rw.statusCode),
-				slog.Int64("processing_ms", duration),
-				slog.String("client_ip", r.RemoteAddr),
-				slog.String("user_agent", r.UserAgent()),
-			)
-		}
-	})
+	duration := time.Since(start).Milliseconds()
+
+	logLevel := slog.LevelInfo
+	if rw.statusCode >= 400 && rw.statusCode < 500 {
+		logLevel = slog.LevelWarn
+	} else if rw.statusCode >= 500 || aborted {
+		logLevel = slog.LevelError
+	}
+
+	attrs := []any{
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status_code", rw.statusCode),
+		slog.Int64("response_bytes", rw.bytesWritten),
+		slog.Int64("processing_ms", duration),
+		slog.String("client_ip", ClientIP(r)),
+		slog.String("user_agent", r.UserAgent()),
+	}
+	if aborted {
+		attrs = append(attrs, slog.Bool("aborted", true))
+	}
+	slog.Log(r.Context(), logLevel, fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, rw.statusCode), attrs...)
 }
 
-// CustomLogHandler adds request_id and user_id fields to log records
+// CustomLogHandler adds request_id, user_id and api_version fields to log records
 type CustomLogHandler struct {
 	slog.Handler
 }
 
-// Handle adds request_id and user_id fields to the log record if they exist in the context
+// Handle adds request_id, user_id and api_version fields to the log record if they exist in the context
 func (h *CustomLogHandler) Handle(ctx context.Context, r slog.Record) error {
 	if requestID, ok := ctx.Value(internal.REQUEST_ID_KEY).(string); ok {
 		r.AddAttrs(slog.String(internal.REQUEST_ID_KEY, requestID))
@@ -65,15 +85,30 @@ func (h *CustomLogHandler) Handle(ctx context.Context, r slog.Record) error {
 	if userID, ok := ctx.Value(internal.CLERK_USER_ID_KEY).(string); ok {
 		r.AddAttrs(slog.String(internal.CLERK_USER_ID_KEY, userID))
 	}
+	if apiVersion, ok := ctx.Value(internal.API_VERSION_KEY).(string); ok {
+		r.AddAttrs(slog.String(internal.API_VERSION_KEY, apiVersion))
+	}
 	return h.Handler.Handle(ctx, r)
 }
 
 type responseWriter struct {
 	http.ResponseWriter
-	statusCode int
+	statusCode   int
+	bytesWritten int64
 }
 
 func (rw *responseWriter) WriteHeader(statusCode int) {
 	rw.statusCode = statusCode
 	rw.ResponseWriter.WriteHeader(statusCode)
 }
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytesWritten += int64(n)
+	return n, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController, e.g. for flushing
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/anishsharma21/go-web-dev-template/internal"
+)
+
+// RealIPMiddleware resolves the client IP address and adds it to the context. Forwarding
+// headers are only read when the direct peer is one of the trusted proxies, otherwise the peer
+// address is used. Only the first header present of X-Forwarded-For, Forwarded and X-Real-IP is
+// read, so trusted proxies must set or append to X-Forwarded-For when clients can send it.
+func RealIPMiddleware(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientIP := resolveClientIP(r, trustedProxies)
+			ctx := context.WithValue(r.Context(), internal.CLIENT_IP_KEY, clientIP)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ClientIP returns the client IP address resolved by RealIPMiddleware
+func ClientIP(r *http.Request) string {
+	if clientIP, ok := r.Context().Value(internal.CLIENT_IP_KEY).(string); ok {
+		return clientIP
+	}
+	if addr, ok := remoteAddr(r); ok {
+		return addr.String()
+	}
+	return r.RemoteAddr
+}
+
+func resolveClientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	peer, ok := remoteAddr(r)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if !isTrusted(peer, trustedProxies) {
+		return peer.String()
+	}
+
+	// Falling back to another header when the first one present is unusable would let clients
+	// choose their address by sending an invalid entry, so the peer is used instead
+	clientIP, ok := peer, false
+	switch {
+	case len(r.Header.Values("X-Forwarded-For")) > 0:
+		clientIP, ok = closestUntrusted(forwardedForEntries(r.Header.Values("X-Forwarded-For")), trustedProxies)
+	case len(r.Header.Values("Forwarded")) > 0:
+		clientIP, ok = closestUntrusted(forwardedEntries(r.Header.Values("Forwarded")), trustedProxies)
+	case r.Header.Get("X-Real-IP") != "":
+		clientIP, ok = parseIP(r.Header.Get("X-Real-IP"))
+	}
+	if !ok {
+		return peer.String()
+	}
+	return clientIP.String()
+}
+
+// closestUntrusted walks the proxy chain from the nearest hop outwards and returns the first
+// address that isn't a trusted proxy. Entries further left could have been spoofed by the client,
+// so the walk stops at an invalid entry and reports false. If every hop is trusted the furthest
+// is returned.
+func closestUntrusted(entries []string, trustedProxies []netip.Prefix) (netip.Addr, bool) {
+	var addr netip.Addr
+	for i := len(entries) - 1; i >= 0; i-- {
+		var ok bool
+		if addr, ok = parseIP(entries[i]); !ok {
+			return netip.Addr{}, false
+		}
+		if !isTrusted(addr, trustedProxies) {
+			return addr, true
+		}
+	}
+	return addr, addr.IsValid()
+}
+
+// forwardedForEntries splits X-Forwarded-For values, e.g. "203.0.113.7, 10.0.0.2", into entries
+func forwardedForEntries(values []string) []string {
+	var entries []string
+	for _, value := range values {
+		entries = append(entries, strings.Split(value, ",")...)
+	}
+	return entries
+}
+
+// forwardedEntries returns the for= parameters of RFC 7239 Forwarded values,
+// e.g. `for=203.0.113.7;proto=https, for="[2001:db8::1]:4711"`. Elements without one are kept as
+// empty entries, which are invalid, as the hop they describe is unknown.
+func forwardedEntries(values []string) []string {
+	var entries []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			var entry string
+			for _, pair := range strings.Split(element, ";") {
+				key, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(key, "for") {
+					entry = strings.Trim(val, `"`)
+				}
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// parseIP parses an IP address that may include a port and IPv6 brackets
+func parseIP(value string) (netip.Addr, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return netip.Addr{}, false
+	}
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(value, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
@@ -0,0 +1,17 @@
+// Renders the OpenAPI document named by the data-spec-url attribute of #docs with the vendored
+// Swagger UI. It is a separate file rather than an inline script so that the page works under a
+// same-origin Content-Security-Policy.
+(function () {
+  "use strict";
+
+  const root = document.getElementById("docs");
+  window.ui = SwaggerUIBundle({
+    url: root.dataset.specUrl,
+    domNode: root,
+    deepLinking: true,
+    presets: [SwaggerUIBundle.presets.apis],
+    layout: "BaseLayout",
+    // The public validator would be fetched from another origin, which the policy doesn't allow
+    validatorUrl: null,
+  });
+})();
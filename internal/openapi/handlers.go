@@ -0,0 +1,55 @@
+package openapi
+
+import (
+	"embed"
+	"encoding/json"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+)
+
+//go:embed docs
+var docsFS embed.FS
+
+var docsTemplate = template.Must(template.ParseFS(docsFS, "docs/index.html"))
+
+// DocsContentSecurityPolicy only allows the docs page to load Swagger UI and fetch the document,
+// all of which are served by the application, and the data: images in Swagger UI's stylesheet
+const DocsContentSecurityPolicy = "default-src 'self'; img-src 'self' data:; frame-ancestors 'none'"
+
+// Handler serves the document as JSON. The document is encoded once, routes must not be added
+// after the handler is created.
+func (d *Document) Handler() (http.Handler, error) {
+	body, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}), nil
+}
+
+// DocsHandler serves a page rendering the document at specURL with Swagger UI, which is served by
+// DocsAssetsHandler at assetsURL, e.g. "/docs/"
+func DocsHandler(specURL, assetsURL string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		data := struct{ SpecURL, AssetsURL string }{specURL, assetsURL}
+		if err := docsTemplate.Execute(w, data); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to render API docs", "error", err)
+		}
+	})
+}
+
+// DocsAssetsHandler serves the vendored Swagger UI and the docs page's script from the binary, so
+// that the docs work offline. Mount it with the assets URL prefix stripped.
+func DocsAssetsHandler() http.Handler {
+	assets, err := fs.Sub(docsFS, "docs/assets")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServerFS(assets)
+}
@@ -0,0 +1,203 @@
+// Package openapi builds an OpenAPI 3.1 document from the route table, deriving the request and
+// response schemas from the Go types the handlers decode and encode.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/respond"
+)
+
+// Operation documents a single route. Request and the values in Responses are zero values of the
+// types the handler decodes and encodes, e.g. requests.SignUpRequest{} or []models.User{}.
+type Operation struct {
+	Summary     string
+	Description string
+	Tags        []string
+	// Query documents the optional query parameters
+	Query []QueryParameter
+	// Request is the JSON request body, nil for routes without one
+	Request any
+	// Responses maps the success status codes to their JSON body, nil for responses without one,
+	// or to Content for responses offered in several media types. Error responses are documented
+	// as problem details for every operation.
+	Responses map[int]any
+	// Deprecated marks operations of deprecated API versions
+	Deprecated bool
+}
+
+// Content maps each media type a response is offered in to its body, e.g. a list offered as JSON
+// and as NDJSON, where the body documents a single line
+type Content map[string]any
+
+// QueryParameter documents an optional query parameter. Type is a zero value of the type the
+// handler parses it as, e.g. 0 for integers.
+type QueryParameter struct {
+	Name        string
+	Description string
+	Type        any
+}
+
+// Document is an OpenAPI 3.1 document
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components components                       `json:"components"`
+
+	schemas *schemaRegistry
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	SecuritySchemes map[string]securityScheme `json:"securitySchemes,omitempty"`
+}
+
+type securityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []parameter           `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+const bearerAuth = "bearerAuth"
+
+// New creates an empty document
+func New(title, version string) *Document {
+	d := &Document{
+		OpenAPI: "3.1.0",
+		Info:    Info{Title: title, Version: version},
+		Paths:   make(map[string]map[string]*operation),
+		Components: components{
+			SecuritySchemes: map[string]securityScheme{
+				bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+		schemas: newSchemaRegistry(),
+	}
+	d.Components.Schemas = d.schemas.schemas
+	return d
+}
+
+var pathParameter = regexp.MustCompile(`\{([^}]+)\}`)
+
+// Add documents the route registered with the ServeMux pattern, e.g. "GET /v1/users/{id}".
+// Authenticated routes require a bearer session token.
+func (d *Document) Add(pattern string, op Operation, authenticated bool) error {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok || !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("route %q must have a method and a path without a host", pattern)
+	}
+	method = strings.ToLower(method)
+
+	documented := &operation{
+		Summary:     op.Summary,
+		Description: op.Description,
+		Tags:        op.Tags,
+		Responses:   make(map[string]response),
+		Deprecated:  op.Deprecated,
+	}
+
+	// Wildcards matching the rest of the path, {name...}, are documented as ordinary parameters
+	path = strings.ReplaceAll(path, "...}", "}")
+	for _, match := range pathParameter.FindAllStringSubmatch(path, -1) {
+		documented.Parameters = append(documented.Parameters, parameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+
+	for _, query := range op.Query {
+		documented.Parameters = append(documented.Parameters, parameter{
+			Name:        query.Name,
+			In:          "query",
+			Description: query.Description,
+			Schema:      d.schemas.schemaFor(reflect.TypeOf(query.Type)),
+		})
+	}
+
+	if op.Request != nil {
+		documented.RequestBody = &requestBody{
+			Required: true,
+			Content:  map[string]mediaType{"application/json": {Schema: d.schemas.schemaFor(reflect.TypeOf(op.Request))}},
+		}
+	}
+
+	if len(op.Responses) == 0 {
+		return fmt.Errorf("route %q must document at least one response", pattern)
+	}
+	for status, body := range op.Responses {
+		r := response{Description: http.StatusText(status)}
+		content, ok := body.(Content)
+		if !ok && body != nil {
+			content = Content{"application/json": body}
+		}
+		if len(content) > 0 {
+			r.Content = make(map[string]mediaType, len(content))
+			for contentType, body := range content {
+				r.Content[contentType] = mediaType{Schema: d.schemas.schemaFor(reflect.TypeOf(body))}
+			}
+		}
+		documented.Responses[strconv.Itoa(status)] = r
+	}
+	documented.Responses["default"] = response{
+		Description: "Error",
+		Content: map[string]mediaType{
+			"application/problem+json": {Schema: d.schemas.schemaFor(reflect.TypeOf(respond.Problem{}))},
+		},
+	}
+
+	if authenticated {
+		documented.Security = []map[string][]string{{bearerAuth: {}}}
+	}
+
+	if d.Paths[path] == nil {
+		d.Paths[path] = make(map[string]*operation)
+	}
+	d.Paths[path][method] = documented
+	return nil
+}
@@ -0,0 +1,187 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of JSON Schema 2020-12 used to describe request and response bodies
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+}
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+// schemaRegistry holds the schemas of named struct types, which are referenced by name
+type schemaRegistry struct {
+	schemas map[string]*Schema
+	types   map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{
+		schemas: make(map[string]*Schema),
+		types:   make(map[reflect.Type]string),
+	}
+}
+
+func (s *schemaRegistry) schemaFor(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		// Any JSON value
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := s.schemaFor(t.Elem())
+		if typ, ok := schema.Type.(string); ok {
+			schema.Type = []string{typ, "null"}
+		}
+		return schema
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + s.register(t)}
+	default:
+		return &Schema{}
+	}
+}
+
+// register adds the schema of a named struct to the components, prefixing the package name when
+// two packages declare types with the same name
+func (s *schemaRegistry) register(t reflect.Type) string {
+	if name, ok := s.types[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := s.schemas[name]; taken {
+		pkg := t.PkgPath()
+		name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+	}
+	// Registered before the fields so that recursive types refer to themselves
+	s.types[t] = name
+	s.schemas[name] = &Schema{}
+	*s.schemas[name] = *s.structSchema(t)
+	return name
+}
+
+func (s *schemaRegistry) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	s.addFields(schema, t)
+	return schema
+}
+
+func (s *schemaRegistry) addFields(schema *Schema, t reflect.Type) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			// encoding/json promotes the fields of embedded structs
+			s.addFields(schema, field.Type)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := s.schemaFor(field.Type)
+		rules, hasRules := field.Tag.Lookup("validate")
+		applyRules(property, strings.Split(rules, ","))
+		schema.Properties[name] = property
+
+		// Request fields are required when validation says so, response fields are always
+		// present unless they are omitted when empty
+		omitempty := slices.Contains(strings.Split(options, ","), "omitempty")
+		if slices.Contains(strings.Split(rules, ","), "required") || (!hasRules && !omitempty) {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// applyRules documents the constraints of the validate package's rules
+func applyRules(schema *Schema, rules []string) {
+	if schema.Ref != "" {
+		return
+	}
+	typ, _ := schema.Type.(string)
+	if types, ok := schema.Type.([]string); ok {
+		typ = types[0]
+	}
+
+	for _, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "email":
+			schema.Format = "email"
+		case "oneof":
+			schema.Enum = strings.Fields(param)
+		case "min", "max":
+			bound, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			n := int(bound)
+			switch {
+			case typ == "string" && name == "min":
+				schema.MinLength = &n
+			case typ == "string":
+				schema.MaxLength = &n
+			case typ == "array" && name == "min":
+				schema.MinItems = &n
+			case typ == "array":
+				schema.MaxItems = &n
+			case name == "min":
+				schema.Minimum = &bound
+			default:
+				schema.Maximum = &bound
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package respond
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// NotModified sets the ETag and Last-Modified headers of the resource and reports whether the
+// client's cached copy is still current, in which case it has answered a GET or HEAD with 304
+// and the handler should return. If-None-Match takes precedence over If-Modified-Since.
+func NotModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		matched, ok := etagMatches(ifNoneMatch, etag, false)
+		if !ok {
+			return false
+		}
+		if matched != "*" && !strings.HasPrefix(matched, "W/") {
+			// The 304 identifies the representation the client has, which may be compressed
+			w.Header().Set("ETag", matched)
+		}
+	} else if !notModifiedSince(r.Header.Get("If-Modified-Since"), lastModified) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// CheckPreconditions evaluates If-Match, or If-Unmodified-Since when it is absent, before a request
+// changes the resource, so that clients don't overwrite changes they haven't seen. It returns a
+// 412 problem when the client's copy is out of date and nil when the request has no preconditions.
+func CheckPreconditions(r *http.Request, etag string, lastModified time.Time) error {
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if _, ok := etagMatches(ifMatch, etag, true); !ok {
+			return NewProblem(http.StatusPreconditionFailed, "The resource has changed since it was fetched")
+		}
+		return nil
+	}
+
+	if ifUnmodifiedSince := r.Header.Get("If-Unmodified-Since"); ifUnmodifiedSince != "" {
+		since, err := http.ParseTime(ifUnmodifiedSince)
+		if err == nil && lastModified.Truncate(time.Second).After(since) {
+			return NewProblem(http.StatusPreconditionFailed, "The resource has changed since it was fetched")
+		}
+	}
+	return nil
+}
+
+// notModifiedSince reports whether the resource hasn't changed since the If-Modified-Since date,
+// which has a resolution of one second
+func notModifiedSince(ifModifiedSince string, lastModified time.Time) bool {
+	if ifModifiedSince == "" || lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(ifModifiedSince)
+	return err == nil && !lastModified.Truncate(time.Second).After(since)
+}
+
+// contentCodings are the encodings EncodedETag may add to an entity tag
+var contentCodings = []string{"zstd", "br", "gzip"}
+
+// EncodedETag returns the entity tag of the etag's representation compressed with the encoding,
+// e.g. "42-abc" becomes "42-abc-gzip". Each representation needs its own tag for the tag to stay a
+// strong validator, and the precondition checks accept the tag of any encoding of the same version.
+func EncodedETag(etag, encoding string) string {
+	if !strings.HasSuffix(etag, `"`) || len(etag) < 2 {
+		return etag
+	}
+	return etag[:len(etag)-1] + "-" + encoding + `"`
+}
+
+// etagMatches returns the entity tag in the header's list matching the etag, in any encoding.
+// Strong comparison, used by If-Match, never matches weak validators.
+func etagMatches(header, etag string, strong bool) (string, bool) {
+	if strings.TrimSpace(header) == "*" {
+		return "*", true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strong && (strings.HasPrefix(candidate, "W/") || strings.HasPrefix(etag, "W/")) {
+			continue
+		}
+		if unencodedETag(candidate) == unencodedETag(etag) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// unencodedETag strips the weak indicator and any encoding added by EncodedETag
+func unencodedETag(etag string) string {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, encoding := range contentCodings {
+		if encoded := `-` + encoding + `"`; strings.HasSuffix(etag, encoded) {
+			return strings.TrimSuffix(etag, encoded) + `"`
+		}
+	}
+	return etag
+}
@@ -0,0 +1,95 @@
+package respond
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Format is a response encoding a handler can offer
+type Format struct {
+	// Name selects the format with the ?format= query parameter
+	Name        string
+	ContentType string
+}
+
+var (
+	JSON   = Format{Name: "json", ContentType: "application/json"}
+	NDJSON = Format{Name: "ndjson", ContentType: "application/x-ndjson"}
+	CSV    = Format{Name: "csv", ContentType: "text/csv"}
+)
+
+// Negotiate picks the format of the response from the offers, preferring the ?format= query
+// parameter over the Accept header, which browsers and spreadsheet tools can't always set. The
+// first offer is the default when neither is given. A format that isn't offered is answered with
+// a 400 problem for the query parameter and a 406 problem for the Accept header.
+func Negotiate(r *http.Request, offers ...Format) (Format, error) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		for _, offer := range offers {
+			if offer.Name == name {
+				return offer, nil
+			}
+		}
+		problem := NewProblem(http.StatusBadRequest, "Query has invalid parameters")
+		problem.Errors = []FieldError{{Field: "format", Message: "must be one of " + formatNames(offers)}}
+		return Format{}, problem
+	}
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return offers[0], nil
+	}
+
+	best, bestQ := -1, 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		for i, offer := range offers {
+			// Ties go to the offer listed first by the handler
+			if q > 0 && matchesMediaRange(offer.ContentType, mediaType) && (q > bestQ || (q == bestQ && i < best)) {
+				best, bestQ = i, q
+			}
+		}
+	}
+	if best < 0 {
+		return Format{}, NewProblem(http.StatusNotAcceptable, fmt.Sprintf("Response can be encoded as %s", contentTypes(offers)))
+	}
+	return offers[best], nil
+}
+
+func matchesMediaRange(contentType, mediaRange string) bool {
+	if mediaRange == "*/*" || mediaRange == contentType {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(mediaRange, "/*")
+	return ok && strings.HasPrefix(contentType, prefix+"/")
+}
+
+func formatNames(offers []Format) string {
+	var names []string
+	for _, offer := range offers {
+		names = append(names, offer.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func contentTypes(offers []Format) string {
+	var types []string
+	for _, offer := range offers {
+		if !slices.Contains(types, offer.ContentType) {
+			types = append(types, offer.ContentType)
+		}
+	}
+	return strings.Join(types, ", ")
+}
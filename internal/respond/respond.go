@@ -0,0 +1,123 @@
+package respond
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/anishsharma21/go-web-dev-template/internal"
+	"github.com/anishsharma21/go-web-dev-template/internal/apperr"
+)
+
+// Problem is an RFC 9457 problem details response. It implements error, so it can be returned by
+// lower layers and passed to Error unchanged.
+type Problem struct {
+	// Type identifies the kind of problem, e.g. /problems/not-found, clients should match on it
+	// rather than on the human readable title and detail
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes why a single request field is invalid
+type FieldError struct {
+	// Field is the JSON name of the field, or a path such as "addresses[0].city"
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// NewProblem creates a problem for the status, with a type derived from its status text
+func NewProblem(status int, detail string) *Problem {
+	title := http.StatusText(status)
+	return &Problem{
+		Type:   "/problems/" + strings.ReplaceAll(strings.ToLower(title), " ", "-"),
+		Title:  title,
+		Status: status,
+		Detail: detail,
+	}
+}
+
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Title + ": " + p.Detail
+}
+
+var domainStatuses = []struct {
+	err    error
+	status int
+}{
+	{apperr.ErrInvalidInput, http.StatusBadRequest},
+	{apperr.ErrUnauthorized, http.StatusUnauthorized},
+	{apperr.ErrForbidden, http.StatusForbidden},
+	{apperr.ErrNotFound, http.StatusNotFound},
+	{apperr.ErrConflict, http.StatusConflict},
+}
+
+// Error writes err as a problem. A *Problem is written as it is, domain errors from the apperr
+// package get their matching status with the *apperr.Error message, or the error message for bare
+// sentinels, as the detail, exceeded deadlines get a 504 like TimeoutMiddleware's, and any other
+// error is logged and answered with a 500 which doesn't reveal its message.
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		WriteProblem(w, r, problem)
+		return
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		slog.ErrorContext(r.Context(), "Request timed out", "error", err)
+		WriteProblem(w, r, NewProblem(http.StatusGatewayTimeout, "Request timed out"))
+		return
+	}
+
+	for _, domain := range domainStatuses {
+		if errors.Is(err, domain.err) {
+			// An *apperr.Error carries a message meant for clients, the rest of the chain may hold
+			// details such as constraint names which are only logged
+			detail := err.Error()
+			var appErr *apperr.Error
+			if errors.As(err, &appErr) {
+				detail = appErr.Message
+				slog.InfoContext(r.Context(), "Request failed", "status", domain.status, "error", err)
+			}
+			WriteProblem(w, r, NewProblem(domain.status, detail))
+			return
+		}
+	}
+
+	slog.ErrorContext(r.Context(), "Internal server error", "error", err)
+	WriteProblem(w, r, NewProblem(http.StatusInternalServerError, "An unexpected error occurred"))
+}
+
+// Problemf writes a problem with the status and detail
+func Problemf(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	WriteProblem(w, r, NewProblem(status, detail))
+}
+
+// WriteProblem writes the problem as application/problem+json, adding the request path and ID so
+// that a client reporting an error can be matched to the server logs
+func WriteProblem(w http.ResponseWriter, r *http.Request, problem *Problem) {
+	p := *problem
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	if requestID, ok := r.Context().Value(internal.REQUEST_ID_KEY).(string); ok && p.RequestID == "" {
+		p.RequestID = requestID
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to encode problem response", "error", err)
+	}
+}
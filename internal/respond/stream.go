@@ -0,0 +1,227 @@
+package respond
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// flushEvery is the number of items written between flushes, so that clients receive a large
+// export as it is produced
+const flushEvery = 500
+
+// StreamList writes the items as a JSON array, newline delimited JSON or CSV with a header row, one
+// item at a time, so that a list read straight from the database is never held in memory. Errors
+// before the first item are written as a problem. Once the response has started its status can't
+// change, so a later error aborts the connection and the client sees an incomplete response rather
+// than a truncated list that looks complete.
+func StreamList[T any](w http.ResponseWriter, r *http.Request, format Format, items iter.Seq2[T, error]) {
+	encoder, err := newListEncoder[T](w, format)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	rc := http.NewResponseController(w)
+
+	started := false
+	count := 0
+	for item, err := range items {
+		if err != nil {
+			if !started {
+				Error(w, r, err)
+				return
+			}
+			slog.ErrorContext(r.Context(), "Failed to stream list, aborting response", "error", err, "items_written", count)
+			panic(http.ErrAbortHandler)
+		}
+
+		if !started {
+			started = true
+			startList(w, format)
+			if err := encoder.begin(); err != nil {
+				return
+			}
+		}
+		if err := encoder.encode(item); err != nil {
+			// The client has most likely gone away
+			slog.WarnContext(r.Context(), "Failed to write list item", "error", err, "items_written", count)
+			return
+		}
+
+		count++
+		if count%flushEvery == 0 {
+			rc.Flush()
+		}
+	}
+
+	if !started {
+		startList(w, format)
+		if err := encoder.begin(); err != nil {
+			return
+		}
+	}
+	if err := encoder.end(); err != nil {
+		slog.WarnContext(r.Context(), "Failed to finish list", "error", err, "items_written", count)
+	}
+}
+
+func startList(w http.ResponseWriter, format Format) {
+	contentType := format.ContentType
+	if format == CSV {
+		contentType += "; charset=utf-8; header=present"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+}
+
+type listEncoder[T any] interface {
+	begin() error
+	encode(item T) error
+	end() error
+}
+
+func newListEncoder[T any](w io.Writer, format Format) (listEncoder[T], error) {
+	switch format {
+	case JSON:
+		return &jsonArrayEncoder[T]{w: w}, nil
+	case NDJSON:
+		return &ndjsonEncoder[T]{encoder: json.NewEncoder(w)}, nil
+	case CSV:
+		return newCSVEncoder[T](w)
+	}
+	return nil, fmt.Errorf("unsupported list format %q", format.Name)
+}
+
+type jsonArrayEncoder[T any] struct {
+	w     io.Writer
+	wrote bool
+}
+
+func (e *jsonArrayEncoder[T]) begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonArrayEncoder[T]) encode(item T) error {
+	b, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if e.wrote {
+		b = append([]byte(","), b...)
+	}
+	e.wrote = true
+	_, err = e.w.Write(b)
+	return err
+}
+
+func (e *jsonArrayEncoder[T]) end() error {
+	_, err := io.WriteString(e.w, "]\n")
+	return err
+}
+
+type ndjsonEncoder[T any] struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonEncoder[T]) begin() error        { return nil }
+func (e *ndjsonEncoder[T]) encode(item T) error { return e.encoder.Encode(item) }
+func (e *ndjsonEncoder[T]) end() error          { return nil }
+
+// csvEncoder writes a column for each field of a flat struct, named by its JSON name
+type csvEncoder[T any] struct {
+	writer *csv.Writer
+	header []string
+	fields []int
+	record []string
+}
+
+func newCSVEncoder[T any](w io.Writer) (*csvEncoder[T], error) {
+	t := reflect.TypeFor[T]()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("CSV lists must be of structs, got %s", t)
+	}
+
+	e := &csvEncoder[T]{writer: csv.NewWriter(w)}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		e.header = append(e.header, name)
+		e.fields = append(e.fields, i)
+	}
+	e.record = make([]string, len(e.fields))
+	return e, nil
+}
+
+func (e *csvEncoder[T]) begin() error {
+	return e.writer.Write(e.header)
+}
+
+func (e *csvEncoder[T]) encode(item T) error {
+	v := reflect.ValueOf(item)
+	for i, field := range e.fields {
+		value, err := csvValue(v.Field(field))
+		if err != nil {
+			return fmt.Errorf("failed to encode CSV column %s: %w", e.header[i], err)
+		}
+		e.record[i] = value
+	}
+	if err := e.writer.Write(e.record); err != nil {
+		return err
+	}
+	// csv.Writer buffers, flushing after each record lets the response writer decide when to send
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvEncoder[T]) end() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// csvValue formats a field as a CSV cell, nil pointers are empty and values without a natural
+// text form are written as JSON
+func csvValue(v reflect.Value) (string, error) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+
+	switch value := v.Interface().(type) {
+	case time.Time:
+		return value.Format(time.RFC3339Nano), nil
+	case fmt.Stringer:
+		return value.String(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	}
+	b, err := json.Marshal(v.Interface())
+	return string(b), err
+}
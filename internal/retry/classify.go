@@ -0,0 +1,11 @@
+package retry
+
+import (
+	"net/http"
+)
+
+// HTTPStatusRetryable reports whether an outbound HTTP request which failed with the status is
+// worth retrying: rate limits and server errors
+func HTTPStatusRetryable(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusRequestTimeout || status >= 500
+}
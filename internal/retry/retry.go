@@ -0,0 +1,116 @@
+// Package retry retries operations with exponential backoff and jitter, giving up after a maximum
+// elapsed time or number of attempts, when the context is cancelled, or on errors which retrying
+// can't fix
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Policy configures how an operation is retried
+type Policy struct {
+	// InitialInterval is the wait after the first failed attempt
+	InitialInterval time.Duration
+	// MaxInterval caps the wait between attempts
+	MaxInterval time.Duration
+	// Multiplier grows the wait after each failed attempt
+	Multiplier float64
+	// Jitter randomises each wait by up to this fraction, e.g. 0.5 waits between 50% and 150%,
+	// so instances which failed together don't all retry at the same moment
+	Jitter float64
+	// MaxElapsed gives up once no further attempt can start within this time (0 = no limit)
+	MaxElapsed time.Duration
+	// MaxAttempts gives up after this many attempts (0 = no limit)
+	MaxAttempts int
+	// Retryable reports whether an error is worth retrying, nil retries every error
+	Retryable func(error) bool
+	// OnRetry is called after a failed attempt, before waiting
+	OnRetry func(attempt int, err error, wait time.Duration)
+}
+
+// DefaultPolicy waits 500ms, doubling up to 10s with 50% jitter, for at most a minute
+func DefaultPolicy() Policy {
+	return Policy{
+		InitialInterval: 500 * time.Millisecond,
+		MaxInterval:     10 * time.Second,
+		Multiplier:      2,
+		Jitter:          0.5,
+		MaxElapsed:      time.Minute,
+	}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error as not worth retrying, whatever the policy's Retryable says
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// Do calls fn until it succeeds or the policy gives up, and returns the last error. Waiting stops
+// as soon as ctx is cancelled.
+func Do(ctx context.Context, policy Policy, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	interval := policy.InitialInterval
+
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+
+		var permanent permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if policy.Retryable != nil && !policy.Retryable(err) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return errors.Join(ctx.Err(), err)
+		}
+		if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			return fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+		}
+
+		wait := policy.jitter(interval)
+		if policy.MaxElapsed > 0 && time.Since(start)+wait > policy.MaxElapsed {
+			return fmt.Errorf("gave up after %d attempts in %s: %w", attempt, time.Since(start).Round(time.Millisecond), err)
+		}
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, err, wait)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(ctx.Err(), err)
+		case <-timer.C:
+		}
+
+		interval = time.Duration(float64(interval) * policy.Multiplier)
+		if policy.MaxInterval > 0 && interval > policy.MaxInterval {
+			interval = policy.MaxInterval
+		}
+	}
+}
+
+func (p Policy) jitter(interval time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return interval
+	}
+	delta := p.Jitter * float64(interval)
+	return time.Duration(float64(interval) - delta + rand.Float64()*2*delta)
+}
@@ -0,0 +1,197 @@
+// Package router registers routes on an http.ServeMux in groups sharing a path prefix and an
+// ordered middleware stack, and keeps a listing of every route for documentation and debugging.
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/openapi"
+)
+
+// Middleware is a named http middleware, the name identifies it in the route listing
+type Middleware struct {
+	Name string
+	Wrap func(http.Handler) http.Handler
+}
+
+// Named names a middleware
+func Named(name string, wrap func(http.Handler) http.Handler) Middleware {
+	return Middleware{Name: name, Wrap: wrap}
+}
+
+// Route is a registered route
+type Route struct {
+	// Name identifies the route, e.g. "users.get", for building its path with Router.Path
+	Name string `json:"name"`
+	// Pattern is the ServeMux pattern, e.g. "GET /v1/users/{id}"
+	Pattern string `json:"pattern"`
+	// Version is the API version the route belongs to, empty for unversioned routes
+	Version string `json:"version,omitempty"`
+	// Middleware lists the middleware wrapping the handler, outermost first
+	Middleware []string `json:"middleware"`
+	// Doc documents the route in the OpenAPI document
+	Doc *openapi.Operation `json:"-"`
+	// Internal routes, such as static files, aren't part of the API and are left out of the document
+	Internal bool `json:"internal,omitempty"`
+}
+
+// Document sets the route's OpenAPI documentation
+func (rt *Route) Document(op openapi.Operation) *Route {
+	rt.Doc = &op
+	return rt
+}
+
+// MarkInternal leaves the route out of the OpenAPI document
+func (rt *Route) MarkInternal() *Route {
+	rt.Internal = true
+	return rt
+}
+
+// Uses reports whether the named middleware wraps the route
+func (rt *Route) Uses(name string) bool {
+	return slices.Contains(rt.Middleware, name)
+}
+
+// Router is a group of routes. Groups created from it share its ServeMux and route listing.
+type Router struct {
+	mux        *http.ServeMux
+	routes     *[]*Route
+	prefix     string
+	middleware []Middleware
+	version    string
+}
+
+// New creates an empty router
+func New() *Router {
+	return &Router{mux: http.NewServeMux(), routes: &[]*Route{}}
+}
+
+// Group creates a group of routes under the prefix, e.g. "/v1", which may be empty to only add
+// middleware. The group's middleware runs inside that of its parents, in the order given.
+func (r *Router) Group(prefix string, middleware ...Middleware) *Router {
+	return &Router{
+		mux:        r.mux,
+		routes:     r.routes,
+		prefix:     r.prefix + prefix,
+		middleware: append(slices.Clone(r.middleware), middleware...),
+		version:    r.version,
+	}
+}
+
+// Version creates a group for the API version under the /<version> prefix. Route names in the
+// group are prefixed with the version, e.g. "v1.users.get", so that a handler shared between
+// versions can be registered for each of them under the same name.
+func (r *Router) Version(version string, middleware ...Middleware) *Router {
+	group := r.Group("/"+version, middleware...)
+	group.version = version
+	return group
+}
+
+// Handle registers the handler for the method and the path relative to the group, wrapped in the
+// group's middleware and then the route's own, outermost first. Names must be unique.
+func (r *Router) Handle(name, method, path string, handler http.Handler, middleware ...Middleware) *Route {
+	if r.version != "" {
+		name = r.version + "." + name
+	}
+	if r.Lookup(name) != nil {
+		panic(fmt.Sprintf("router: duplicate route name %q", name))
+	}
+
+	pattern := r.prefix + path
+	if method != "" {
+		pattern = method + " " + pattern
+	}
+
+	stack := append(slices.Clone(r.middleware), middleware...)
+	route := &Route{Name: name, Pattern: pattern, Version: r.version}
+	for i := len(stack) - 1; i >= 0; i-- {
+		handler = stack[i].Wrap(handler)
+	}
+	for _, mw := range stack {
+		route.Middleware = append(route.Middleware, mw.Name)
+	}
+
+	r.mux.Handle(pattern, handler)
+	*r.routes = append(*r.routes, route)
+	return route
+}
+
+// Routes lists every route in the order they were registered
+func (r *Router) Routes() []*Route {
+	return slices.Clone(*r.routes)
+}
+
+// Lookup returns the named route, or nil if there is none
+func (r *Router) Lookup(name string) *Route {
+	for _, route := range *r.routes {
+		if route.Name == name {
+			return route
+		}
+	}
+	return nil
+}
+
+// Path builds the path of the named route, filling its wildcards in order with the values, e.g.
+// Path("users.get", "user_123") returns "/v1/users/user_123"
+func (r *Router) Path(name string, values ...string) (string, error) {
+	route := r.Lookup(name)
+	if route == nil {
+		return "", fmt.Errorf("no route named %q", name)
+	}
+	_, path, found := strings.Cut(route.Pattern, " ")
+	if !found {
+		path = route.Pattern
+	}
+
+	var built strings.Builder
+	for {
+		start := strings.Index(path, "{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(path[start:], "}") + start
+		wildcard := path[start : end+1]
+		built.WriteString(path[:start])
+		path = path[end+1:]
+		if wildcard == "{$}" {
+			// Only marks that the path must end here
+			continue
+		}
+		if len(values) == 0 {
+			return "", fmt.Errorf("route %q needs a value for %s", name, wildcard)
+		}
+		if strings.HasSuffix(wildcard, "...}") {
+			built.WriteString(values[0])
+		} else {
+			built.WriteString(url.PathEscape(values[0]))
+		}
+		values = values[1:]
+	}
+	if len(values) > 0 {
+		return "", fmt.Errorf("route %q has %d more values than wildcards", name, len(values))
+	}
+	built.WriteString(path)
+	return built.String(), nil
+}
+
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mux.ServeHTTP(w, req)
+}
+
+// ListingHandler serves the route listing as JSON, for debugging which middleware wraps a route
+func (r *Router) ListingHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(r.Routes()); err != nil {
+			slog.ErrorContext(req.Context(), "Failed to encode route listing", "error", err)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned by a Provider that doesn't hold the requested secret
+var ErrNotFound = errors.New("secret not found")
+
+// Provider retrieves secrets from a secret store. Implementations must be safe for concurrent
+// use, as secrets are re-read periodically to pick up rotations.
+type Provider interface {
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// LoadFunc loads the current value of a single secret
+type LoadFunc func(ctx context.Context) (string, error)
+
+// FileProvider reads each secret from a file named after the secret in Dir, which matches how
+// Docker (/run/secrets) and Kubernetes secret volumes are mounted
+type FileProvider struct {
+	Dir string
+}
+
+func (p FileProvider) Secret(_ context.Context, name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid secret name %q", name)
+	}
+	value, err := ReadFile(filepath.Join(p.Dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return value, err
+}
+
+// ReadFile reads a secret from a file, ignoring surrounding whitespace such as the trailing
+// newline most editors and `echo` add
+func ReadFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// FromProvider returns a LoadFunc for a named secret in a provider
+func FromProvider(provider Provider, name string) LoadFunc {
+	return func(ctx context.Context) (string, error) {
+		return provider.Secret(ctx, name)
+	}
+}
+
+// FromFile returns a LoadFunc that reads a secret from a file
+func FromFile(path string) LoadFunc {
+	return func(context.Context) (string, error) {
+		return ReadFile(path)
+	}
+}
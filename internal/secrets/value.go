@@ -0,0 +1,99 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Value holds the current value of a secret which may be rotated while the process is running
+type Value struct {
+	name string
+	load LoadFunc
+
+	mu       sync.RWMutex
+	current  string
+	onChange []func(string)
+}
+
+// NewValue creates a Value with an initial value. If load is nil the value never changes.
+func NewValue(name, initial string, load LoadFunc) *Value {
+	return &Value{name: name, current: initial, load: load}
+}
+
+// Get returns the current value of the secret
+func (v *Value) Get() string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.current
+}
+
+// OnChange registers a function which is called with the new value after each rotation
+func (v *Value) OnChange(fn func(string)) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.onChange = append(v.onChange, fn)
+}
+
+// Reloadable reports whether the secret has a source it can be reloaded from
+func (v *Value) Reloadable() bool {
+	return v.load != nil
+}
+
+// Refresh reloads the secret and reports whether it changed. An empty value is treated as an
+// error, since it usually means the file is being rewritten, and the old value is kept.
+func (v *Value) Refresh(ctx context.Context) (bool, error) {
+	if v.load == nil {
+		return false, nil
+	}
+
+	value, err := v.load(ctx)
+	if err != nil {
+		return false, err
+	}
+	if value == "" {
+		return false, errors.New("secret is empty")
+	}
+
+	v.mu.Lock()
+	if value == v.current {
+		v.mu.Unlock()
+		return false, nil
+	}
+	v.current = value
+	callbacks := append([]func(string){}, v.onChange...)
+	v.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn(value)
+	}
+	return true, nil
+}
+
+// Watch refreshes the secret every interval until the context is cancelled
+func (v *Value) Watch(ctx context.Context, interval time.Duration) {
+	if v.load == nil || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := v.Refresh(ctx)
+			if err != nil {
+				slog.WarnContext(ctx, "Failed to reload secret, keeping current value", "secret", v.name, "error", err)
+				continue
+			}
+			if changed {
+				slog.InfoContext(ctx, "Reloaded rotated secret", "secret", v.name)
+			}
+		}
+	}
+}
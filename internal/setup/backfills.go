@@ -0,0 +1,21 @@
+package setup
+
+import (
+	"github.com/anishsharma21/go-web-dev-template/internal/backfill"
+	"github.com/anishsharma21/go-web-dev-template/internal/config"
+	"github.com/clerk/clerk-sdk-go/v2"
+	"github.com/clerk/clerk-sdk-go/v2/user"
+)
+
+// Backfills returns every backfill job, they only run once scheduled by a migration
+func Backfills(cfg *config.Config) []backfill.Job {
+	clerkUsers := user.NewClient(&clerk.ClientConfig{
+		BackendConfig: clerk.BackendConfig{
+			Key: clerk.String(cfg.Clerk.SecretKey),
+		},
+	})
+
+	return []backfill.Job{
+		backfill.ClerkUserProfiles(clerkUsers, cfg.Backfill.BatchSize, cfg.Backfill.Pause),
+	}
+}
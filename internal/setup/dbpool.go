@@ -4,49 +4,108 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"os"
 	"time"
 
+	"github.com/anishsharma21/go-web-dev-template/internal/config"
+	"github.com/anishsharma21/go-web-dev-template/internal/db"
+	"github.com/anishsharma21/go-web-dev-template/internal/retry"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func DBPool(ctx context.Context, dbConnStr string) (*pgxpool.Pool, error) {
-	config, err := pgxpool.ParseConfig(dbConnStr)
+func DBPool(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
+	poolConfig, err := parsePoolConfig(cfg.DatabaseURL, cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	dbPool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to initialise database connection pool: %v", err)
+	}
+
+	// The database may still be starting, e.g. alongside this server in docker compose
+	policy := retry.DefaultPolicy()
+	policy.MaxElapsed = cfg.Database.StartupTimeout
+	policy.Retryable = db.Retryable
+	policy.OnRetry = func(attempt int, err error, wait time.Duration) {
+		slog.Warn("Failed to ping database connection pool", "error", err, "attempt", attempt, "retry_in", wait.String())
+	}
+	if err := retry.Do(ctx, policy, dbPool.Ping); err != nil {
+		dbPool.Close()
+		return nil, fmt.Errorf("Failed to ping database connection pool: %w", err)
+	}
+
+	return dbPool, nil
+}
+
+// ReplicaPool creates the read replica pool, or returns nil if no replica is configured. Unlike the
+// primary it isn't pinged on startup, an unreachable replica only means reads go to the primary.
+func ReplicaPool(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
+	if cfg.Database.ReplicaURL == "" {
+		return nil, nil
+	}
+
+	poolConfig, err := parsePoolConfig(cfg.Database.ReplicaURL, cfg.Database)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse replica connection string: %v", err)
+	}
+	replicaPool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to initialise replica connection pool: %v", err)
+	}
+	return replicaPool, nil
+}
+
+var queryExecModes = map[string]pgx.QueryExecMode{
+	"cache_statement": pgx.QueryExecModeCacheStatement,
+	"cache_describe":  pgx.QueryExecModeCacheDescribe,
+	"describe_exec":   pgx.QueryExecModeDescribeExec,
+	"exec":            pgx.QueryExecModeExec,
+	"simple_protocol": pgx.QueryExecModeSimpleProtocol,
+}
+
+func parsePoolConfig(connStr string, dbConfig config.DatabaseConfig) (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(connStr)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to parse database connection string: %v", err)
 	}
 
-	// Set connection pool configurations
+	// Zero values keep the defaults, which may also have been set in the connection string
+	if dbConfig.MaxConns > 0 {
+		poolConfig.MaxConns = int32(dbConfig.MaxConns)
+	}
+	// To prevent database and backend from ever sleeping, keep DB_MIN_CONNS at 1 or more
+	if dbConfig.MinConns > 0 {
+		poolConfig.MinConns = int32(dbConfig.MinConns)
+	}
+	if dbConfig.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = dbConfig.MaxConnLifetime
+	}
+	if dbConfig.MaxConnLifetimeJitter > 0 {
+		poolConfig.MaxConnLifetimeJitter = dbConfig.MaxConnLifetimeJitter
+	}
 	// Sets the maximum time an idle connection can remain in the pool before being closed
-	config.MaxConnIdleTime = 1 * time.Minute
-	// To prevent database and backend from ever sleeping, uncomment the following line
-	config.MinConns = 1
-
-	var dbPool *pgxpool.Pool
-	for i := 1; i <= 5; i++ {
-		dbPool, err = pgxpool.NewWithConfig(ctx, config)
-		if err == nil && dbPool != nil {
-			break
-		}
-		slog.Warn("Failed to initialise database connection pool", "error", err)
-		slog.Info(fmt.Sprintf("Retrying in %d seconds...", i*i))
-		time.Sleep(time.Duration(i*i) * time.Second)
+	if dbConfig.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = dbConfig.MaxConnIdleTime
+	}
+	if dbConfig.HealthCheckPeriod > 0 {
+		poolConfig.HealthCheckPeriod = dbConfig.HealthCheckPeriod
 	}
-	if dbPool == nil {
-		return nil, fmt.Errorf("Failed to initialise database connection pool after 5 attempts")
+	if dbConfig.ConnectTimeout > 0 {
+		poolConfig.ConnConfig.ConnectTimeout = dbConfig.ConnectTimeout
+	}
+	if mode, ok := queryExecModes[dbConfig.QueryExecMode]; ok {
+		poolConfig.ConnConfig.DefaultQueryExecMode = mode
 	}
 
-	for i := 1; i <= 5; i++ {
-		err = dbPool.Ping(ctx)
-		if err == nil && dbPool != nil {
-			break
+	// Identifies this instance in pg_stat_activity, e.g. when logging who holds the migration lock
+	if _, ok := poolConfig.ConnConfig.RuntimeParams["application_name"]; !ok {
+		if hostname, err := os.Hostname(); err == nil {
+			poolConfig.ConnConfig.RuntimeParams["application_name"] = hostname
 		}
-		slog.Warn("Failed to ping database connection pool", "error", err)
-		slog.Info(fmt.Sprintf("Retrying in %d seconds...", i*i))
-		time.Sleep(time.Duration(i*i) * time.Second)
-	}
-	if err != nil {
-		return nil, fmt.Errorf("Failed to ping database connection pool after 5 attempts")
 	}
 
-	return dbPool, nil
+	return poolConfig, nil
 }
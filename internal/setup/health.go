@@ -0,0 +1,77 @@
+package setup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/config"
+	"github.com/anishsharma21/go-web-dev-template/internal/health"
+	"github.com/clerk/clerk-sdk-go/v2/jwks"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// HealthChecker creates the readiness checks for the database, the migrations and Clerk
+func HealthChecker(cfg *config.Config, dbPool *pgxpool.Pool, migrator *Migrator, jwksClient *jwks.Client) *health.Checker {
+	expectedVersion := migrator.LatestVersion()
+
+	return health.NewChecker(
+		health.Check{
+			Name:    "database",
+			Timeout: cfg.Health.CheckTimeout,
+			Run:     dbPool.Ping,
+		},
+		health.Check{
+			Name:    "migrations",
+			Timeout: cfg.Health.CheckTimeout,
+			Run: func(ctx context.Context) error {
+				version, err := migrator.Version(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to read schema version: %w", err)
+				}
+				// A newer schema is expected during a rolling deploy, when the new version has already migrated
+				if version < expectedVersion {
+					return fmt.Errorf("schema version %d is behind expected version %d", version, expectedVersion)
+				}
+				return nil
+			},
+		},
+		health.Check{
+			Name:    "clerk_jwks",
+			Timeout: cfg.Health.CheckTimeout,
+			Run:     JWKSCheck(jwksClient, cfg.Health.JWKSCacheTTL),
+		},
+	)
+}
+
+// JWKSCheck verifies that the Clerk JWKS can be fetched. Like the auth middleware's key cache, a
+// successful fetch is reused for cacheTTL, so probes don't call Clerk every time and a brief
+// Clerk outage doesn't fail readiness while tokens can still be verified with the cached keys.
+func JWKSCheck(jwksClient *jwks.Client, cacheTTL time.Duration) func(ctx context.Context) error {
+	var mu sync.Mutex
+	var lastSuccess time.Time
+
+	return func(ctx context.Context) error {
+		// The lock only guards the cache, so a slow fetch doesn't hold up concurrent probes
+		mu.Lock()
+		cached := !lastSuccess.IsZero() && time.Since(lastSuccess) < cacheTTL
+		mu.Unlock()
+		if cached {
+			return nil
+		}
+
+		keySet, err := jwksClient.Get(ctx, &jwks.GetParams{})
+		if err != nil {
+			return fmt.Errorf("failed to fetch Clerk JWKS: %w", err)
+		}
+		if len(keySet.Keys) == 0 {
+			return fmt.Errorf("Clerk JWKS contains no keys")
+		}
+
+		mu.Lock()
+		lastSuccess = time.Now()
+		mu.Unlock()
+		return nil
+	}
+}
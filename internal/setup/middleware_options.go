@@ -0,0 +1,29 @@
+package setup
+
+import (
+	"github.com/anishsharma21/go-web-dev-template/internal/config"
+	"github.com/anishsharma21/go-web-dev-template/internal/middleware"
+)
+
+func corsOptions(cfg config.CORSConfig) middleware.CORSOptions {
+	return middleware.CORSOptions{
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowedMethods:   cfg.AllowedMethods,
+		AllowedHeaders:   cfg.AllowedHeaders,
+		ExposedHeaders:   cfg.ExposedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge,
+	}
+}
+
+func securityHeadersOptions(cfg config.SecurityConfig) middleware.SecurityHeadersOptions {
+	return middleware.SecurityHeadersOptions{
+		HSTSMaxAge:            cfg.HSTSMaxAge,
+		HSTSIncludeSubdomains: cfg.HSTSIncludeSubdomains,
+		HSTSPreload:           cfg.HSTSPreload,
+		ContentSecurityPolicy: cfg.ContentSecurityPolicy,
+		FrameOptions:          cfg.FrameOptions,
+		ReferrerPolicy:        cfg.ReferrerPolicy,
+		ContentTypeNosniff:    true,
+	}
+}
@@ -0,0 +1,100 @@
+package setup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrationLockID is the key of the Postgres advisory lock held while migrating. Every instance
+// uses the same key, so only one of them runs migrations at a time.
+const migrationLockID int64 = 7204381920551813302
+
+// ErrMigrationLockTimeout is returned when another instance holds the migration lock for too long
+var ErrMigrationLockTimeout = errors.New("timed out waiting for the migration lock")
+
+// WithLock runs fn while holding the migration lock. If another instance holds the lock, it is
+// retried every pollInterval for up to timeout (0 waits indefinitely), logging who holds it.
+func (m *Migrator) WithLock(ctx context.Context, timeout, pollInterval time.Duration, fn func(context.Context) error) error {
+	// Advisory locks belong to a session, so the same connection has to take and release the lock
+	conn, err := m.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to acquire connection for migration lock: %w", err)
+	}
+	defer conn.Release()
+
+	start := time.Now()
+	for {
+		var acquired bool
+		if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", migrationLockID).Scan(&acquired); err != nil {
+			return fmt.Errorf("Failed to take migration lock: %w", err)
+		}
+		if acquired {
+			break
+		}
+
+		waited := time.Since(start)
+		if timeout > 0 && waited >= timeout {
+			return fmt.Errorf("%w after %s", ErrMigrationLockTimeout, timeout)
+		}
+		logMigrationLockHolder(ctx, conn, waited)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+	slog.Info("Acquired migration lock", "waited", time.Since(start).String())
+
+	defer func() {
+		// The unlock must run even if ctx was cancelled, otherwise the pooled connection keeps the lock
+		unlockCtx := context.WithoutCancel(ctx)
+		if _, err := conn.Exec(unlockCtx, "SELECT pg_advisory_unlock($1)", migrationLockID); err != nil {
+			slog.Error("Failed to release migration lock, closing connection", "error", err)
+			conn.Conn().Close(unlockCtx)
+		}
+	}()
+
+	return fn(ctx)
+}
+
+// logMigrationLockHolder logs which instance holds the migration lock, as identified by the
+// application_name and address of its database session
+func logMigrationLockHolder(ctx context.Context, conn *pgxpool.Conn, waited time.Duration) {
+	var pid int32
+	var applicationName, clientAddr string
+	var backendStart *time.Time
+	err := conn.QueryRow(ctx, `
+		SELECT a.pid, COALESCE(a.application_name, ''), COALESCE(host(a.client_addr), ''), a.backend_start
+		FROM pg_locks l
+		JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.locktype = 'advisory' AND l.granted AND l.objsubid = 1
+			AND ((l.classid::bigint << 32) | l.objid::bigint) = $1`,
+		migrationLockID,
+	).Scan(&pid, &applicationName, &clientAddr, &backendStart)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// The lock was released since it was last tried
+		return
+	}
+	if err != nil {
+		slog.Warn("Waiting for migration lock held by another instance", "waited", waited.String(), "error", err)
+		return
+	}
+
+	attrs := []any{
+		"waited", waited.String(),
+		"holder_pid", pid,
+		"holder_application_name", applicationName,
+		"holder_client_addr", clientAddr,
+	}
+	if backendStart != nil {
+		attrs = append(attrs, "holder_connected_at", backendStart.UTC().Format(time.RFC3339))
+	}
+	slog.Warn("Waiting for migration lock held by another instance", attrs...)
+}
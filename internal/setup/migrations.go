@@ -1,41 +1,214 @@
 package setup
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"log/slog"
+	"strconv"
 
-	"github.com/anishsharma21/go-web-dev-template/internal"
-	_ "github.com/lib/pq"
+	"github.com/anishsharma21/go-web-dev-template/migrations"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/pressly/goose/v3"
 )
 
-func Migrations(dbConnStr string) error {
-	if gooseDriver := os.Getenv(internal.GOOSE_DRIVER); gooseDriver == "" {
-		return fmt.Errorf("Goose driver not set: GOOSE_DRIVER=?")
+// ErrSchemaTooNew is returned when the database has migrations applied that this binary doesn't
+// know about, e.g. after a newer release migrated the database and this one was rolled back to
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+
+// ErrUnknownMigrationCommand is returned by ParseMigrationCommand for commands it doesn't know
+var ErrUnknownMigrationCommand = errors.New("unknown migrate command")
+
+// MigrationCommand is a migrate subcommand which runs against the database: up, down, status, redo
+// or to
+type MigrationCommand struct {
+	Name string
+	// Version is the target version of "to"
+	Version int64
+}
+
+// ParseMigrationCommand parses the arguments of "main migrate" which follow the flags, so that an
+// invalid command is reported before connecting to the database
+func ParseMigrationCommand(args []string) (MigrationCommand, error) {
+	if len(args) == 0 {
+		return MigrationCommand{}, errors.New("No migrate command given")
+	}
+
+	switch command := args[0]; command {
+	case "up", "down", "status", "redo":
+		return MigrationCommand{Name: command}, nil
+	case "to":
+		if len(args) != 2 {
+			return MigrationCommand{}, errors.New("Usage: main migrate to <version>")
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return MigrationCommand{}, fmt.Errorf("Invalid migration version %q", args[1])
+		}
+		return MigrationCommand{Name: command, Version: version}, nil
+	default:
+		return MigrationCommand{}, fmt.Errorf("%w %q", ErrUnknownMigrationCommand, command)
+	}
+}
+
+// CreateMigration writes a new timestamped sql or go migration file to dir
+func CreateMigration(dir, name, migrationType string) error {
+	if migrationType != "sql" && migrationType != "go" {
+		return fmt.Errorf("Migration type must be sql or go, got %q", migrationType)
+	}
+	if err := goose.Create(nil, dir, name, migrationType); err != nil {
+		return fmt.Errorf("Failed to create migration: %w", err)
+	}
+	return nil
+}
+
+// Migrator runs the migrations embedded in the binary
+type Migrator struct {
+	dbPool   *pgxpool.Pool
+	provider *goose.Provider
+}
+
+// NewMigrator creates a migrator which runs migrations over connections from the pool
+func NewMigrator(dbPool *pgxpool.Pool) (*Migrator, error) {
+	db := stdlib.OpenDBFromPool(dbPool)
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, migrations.FS)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Failed to load migrations: %v", err)
+	}
+	return &Migrator{dbPool: dbPool, provider: provider}, nil
+}
+
+// Close releases the migrator's connections back to the pool
+func (m *Migrator) Close() error {
+	return m.provider.Close()
+}
+
+// LatestVersion returns the version of the newest migration known to this binary
+func (m *Migrator) LatestVersion() int64 {
+	sources := m.provider.ListSources()
+	if len(sources) == 0 {
+		return 0
+	}
+	return sources[len(sources)-1].Version
+}
+
+// Version returns the current schema version of the database
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	return m.provider.GetDBVersion(ctx)
+}
+
+// CheckVersion returns ErrSchemaTooNew if the database schema is newer than the latest migration
+// known to this binary
+func (m *Migrator) CheckVersion(ctx context.Context) error {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to read schema version: %w", err)
+	}
+	if latest := m.LatestVersion(); version > latest {
+		return fmt.Errorf("%w: schema version is %d, latest known migration is %d", ErrSchemaTooNew, version, latest)
+	}
+	return nil
+}
+
+// Status returns every migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return m.provider.Status(ctx)
+}
+
+// Up applies all pending migrations. When another instance has just applied them, there is
+// nothing left to do and the schema version is verified instead.
+func (m *Migrator) Up(ctx context.Context) error {
+	if err := m.CheckVersion(ctx); err != nil {
+		return err
 	}
 
-	if gooseDbString := os.Getenv(internal.GOOSE_DBSTRING); gooseDbString == "" {
-		return fmt.Errorf("Goose db string not set: GOOSE_DBSTRING=?")
+	results, err := m.provider.Up(ctx)
+	logMigrationResults(results...)
+	if err != nil {
+		return fmt.Errorf("Failed to apply migrations: %w", err)
 	}
 
-	if gooseMigrationDir := os.Getenv(internal.GOOSE_MIGRATION_DIR); gooseMigrationDir == "" {
-		return fmt.Errorf("Goose migration dir not set: GOOSE_MIGRATION_DIR=?")
+	version, err := m.Version(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to read schema version: %w", err)
 	}
+	if latest := m.LatestVersion(); version != latest {
+		return fmt.Errorf("Schema version is %d after migrating, expected %d", version, latest)
+	}
+	slog.Info("Database schema is up to date", "version", version, "applied", len(results))
+	return nil
+}
 
-	db, err := sql.Open("postgres", dbConnStr)
+// Down rolls back the most recently applied migration
+func (m *Migrator) Down(ctx context.Context) error {
+	result, err := m.provider.Down(ctx)
+	logMigrationResults(result)
 	if err != nil {
-		return fmt.Errorf("Failed to open database connection for *sql.DB: %v\n", err)
+		return fmt.Errorf("Failed to roll back migration: %w", err)
 	}
-	defer db.Close()
+	return nil
+}
 
-	if err = goose.Status(db, "migrations"); err != nil {
-		return fmt.Errorf("Failed to retrieve status of migrations: %v\n", err)
+// Redo rolls back the most recently applied migration and applies it again
+func (m *Migrator) Redo(ctx context.Context) error {
+	if err := m.Down(ctx); err != nil {
+		return err
 	}
+	result, err := m.provider.UpByOne(ctx)
+	logMigrationResults(result)
+	if err != nil {
+		return fmt.Errorf("Failed to reapply migration: %w", err)
+	}
+	return nil
+}
 
-	if err = goose.Up(db, "migrations"); err != nil {
-		return fmt.Errorf("Failed to run `goose up` command: %v\n", err)
+// To migrates up or down to the given version
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	current, err := m.Version(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to read schema version: %w", err)
 	}
 
+	var results []*goose.MigrationResult
+	if version >= current {
+		results, err = m.provider.UpTo(ctx, version)
+	} else {
+		results, err = m.provider.DownTo(ctx, version)
+	}
+	logMigrationResults(results...)
+	if err != nil {
+		return fmt.Errorf("Failed to migrate to version %d: %w", version, err)
+	}
 	return nil
 }
+
+// Run runs a command which changes the schema, status only reads it and is printed by the caller
+func (m *Migrator) Run(ctx context.Context, command MigrationCommand) error {
+	switch command.Name {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		return m.Down(ctx)
+	case "redo":
+		return m.Redo(ctx)
+	case "to":
+		return m.To(ctx, command.Version)
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownMigrationCommand, command.Name)
+	}
+}
+
+func logMigrationResults(results ...*goose.MigrationResult) {
+	for _, result := range results {
+		if result == nil || result.Error != nil {
+			continue
+		}
+		slog.Info("Applied migration",
+			"direction", result.Direction,
+			"version", result.Source.Version,
+			"path", result.Source.Path,
+			"duration", result.Duration.String())
+	}
+}
@@ -3,67 +3,274 @@ package setup
 import (
 	"fmt"
 	"net/http"
+	"time"
 
-	"github.com/anishsharma21/go-web-dev-template/internal"
+	"github.com/anishsharma21/go-web-dev-template/internal/apiversion"
+	"github.com/anishsharma21/go-web-dev-template/internal/config"
+	"github.com/anishsharma21/go-web-dev-template/internal/db"
 	"github.com/anishsharma21/go-web-dev-template/internal/handlers"
+	"github.com/anishsharma21/go-web-dev-template/internal/health"
 	"github.com/anishsharma21/go-web-dev-template/internal/middleware"
+	"github.com/anishsharma21/go-web-dev-template/internal/openapi"
+	"github.com/anishsharma21/go-web-dev-template/internal/respond"
+	"github.com/anishsharma21/go-web-dev-template/internal/router"
+	"github.com/anishsharma21/go-web-dev-template/internal/secrets"
+	"github.com/anishsharma21/go-web-dev-template/internal/types/models"
+	"github.com/anishsharma21/go-web-dev-template/internal/types/requests"
+	"github.com/clerk/clerk-sdk-go/v2/jwks"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-type routeConfig struct {
-	Handler      http.Handler
-	ApplyLogging bool
-	ApplyJWT     bool
+// Dependencies are the long-lived components the routes are built from
+type Dependencies struct {
+	DB            *db.Router
+	JWKSClient    *jwks.Client
+	WebhookSecret *secrets.Value
+	Health        *health.Checker
+	// APIUsage counts the requests each API version receives, optional
+	APIUsage *apiversion.Usage
+	// IdempotencyKeys stores the responses replayed for retried requests, stored in the database
+	// when not set
+	IdempotencyKeys middleware.IdempotencyStore
 }
 
-func Routes(dbPool *pgxpool.Pool) *http.ServeMux {
-	mux := http.NewServeMux()
+func Routes(cfg *config.Config, deps Dependencies) (http.Handler, error) {
+	dbPool := deps.DB.Primary()
 
-	routes := map[string]routeConfig{
-		fmt.Sprintf("POST /%s/signup", internal.API_VERSION): {
-			Handler:      handlers.AddNewUser(dbPool),
-			ApplyLogging: true,
-			ApplyJWT:     false,
-		},
-		fmt.Sprintf("GET /%s/users", internal.API_VERSION): {
-			Handler:      handlers.GetUsers(dbPool),
-			ApplyLogging: true,
-			ApplyJWT:     true,
-		},
-		fmt.Sprintf("GET /%s/users/{clerk_user_id}", internal.API_VERSION): {
-			Handler:      handlers.GetUserByClerkUserId(dbPool),
-			ApplyLogging: true,
-			ApplyJWT:     true,
-		},
-		fmt.Sprintf("DELETE /%s/users/{id}", internal.API_VERSION): {
-			Handler:      handlers.DeleteUserByID(dbPool),
-			ApplyLogging: true,
-			ApplyJWT:     true,
-		},
+	trustedProxies, err := cfg.HTTP.TrustedProxyPrefixes()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse trusted proxies: %v", err)
+	}
+	webhookVerifier, err := handlers.NewWebhookVerifier(deps.WebhookSecret)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create webhook verifier: %v", err)
+	}
 
-		fmt.Sprintf("POST /%s/webhooks", internal.API_VERSION): {
-			Handler:      handlers.ClerkWebhookHandler(dbPool),
-			ApplyLogging: true,
-			ApplyJWT:     false,
-		},
+	securityHeaders := securityHeadersOptions(cfg.Security)
+	// Static files may be rendered by the browser, so they can load resources from the same origin
+	staticSecurityHeaders := securityHeaders
+	staticSecurityHeaders.ContentSecurityPolicy = "default-src 'self'; frame-ancestors 'none'"
 
-		"GET /static/": {
-			Handler:      http.StripPrefix("/static/", http.FileServer(http.Dir("static"))),
-			ApplyLogging: false,
-			ApplyJWT:     false,
-		},
+	// Logging runs before authentication so that every response, including authentication
+	// failures, is logged with a request ID
+	logging := router.Named("logging", middleware.LoggingMiddleware)
+	auth := router.Named("auth", middleware.ClerkAuthMiddleware(deps.JWKSClient))
+	compression := router.Named("compression", middleware.CompressionMiddleware(cfg.HTTP.CompressionMinSize))
+
+	r := router.New()
+
+	usage := deps.APIUsage
+	if usage == nil {
+		usage = apiversion.NewUsage()
+	}
+	idempotencyKeys := deps.IdempotencyKeys
+	if idempotencyKeys == nil {
+		idempotencyKeys = db.NewIdempotencyKeys(dbPool, cfg.Idempotency.KeyTTL)
+	}
+	api := apiRoutes{
+		deps:            deps,
+		dbPool:          dbPool,
+		webhookVerifier: webhookVerifier,
+		auth:            auth,
+		compression:     compression,
+		idempotency:     router.Named("idempotency", middleware.IdempotencyMiddleware(idempotencyKeys)),
+	}
+	// The API versions served side by side, oldest first. To release v2, add it with the route
+	// groups it shares with v1 and its new ones, then set v1's Deprecated and Sunset dates.
+	versions := []struct {
+		apiversion.Version
+		routes []func(*router.Router)
+	}{
+		{apiversion.Version{Name: "v1"}, []func(*router.Router){api.signup, api.webhooks, api.users}},
+	}
+	for _, version := range versions {
+		// The version is added to the context first so that it is included in the request log
+		group := r.Version(version.Name, router.Named("api_version", usage.Middleware(version.Version)), logging)
+		for _, register := range version.routes {
+			register(group)
+		}
+	}
+
+	r.Handle("health.live", http.MethodGet, "/healthz", handlers.Healthz()).Document(openapi.Operation{
+		Summary:   "Report that the process is alive",
+		Tags:      []string{"health"},
+		Responses: map[int]any{http.StatusOK: health.Report{}},
+	})
+	r.Handle("health.ready", http.MethodGet, "/readyz", handlers.Readyz(deps.Health)).Document(openapi.Operation{
+		Summary:   "Report whether the service can handle traffic",
+		Tags:      []string{"health"},
+		Responses: map[int]any{http.StatusOK: health.Report{}, http.StatusServiceUnavailable: health.Report{}},
+	})
+	r.Handle("health.startup", http.MethodGet, "/startupz", handlers.Startupz(deps.Health)).Document(openapi.Operation{
+		Summary:   "Report whether startup work such as migrations has completed",
+		Tags:      []string{"health"},
+		Responses: map[int]any{http.StatusOK: health.Report{}, http.StatusServiceUnavailable: health.Report{}},
+	})
+
+	r.Handle("static", http.MethodGet, "/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))),
+		compression, securityHeadersOverride(staticSecurityHeaders),
+	).MarkInternal()
+
+	deprecated := make(map[string]bool)
+	for _, version := range versions {
+		deprecated[version.Name] = version.IsDeprecated()
+	}
+	if err := addAPIDocs(cfg, r, versions[len(versions)-1].Name, deprecated, securityHeaders, compression); err != nil {
+		return nil, err
+	}
+	if cfg.Environment == config.Development {
+		r.Handle("debug.routes", http.MethodGet, "/debug/routes", r.ListingHandler()).MarkInternal()
+	}
+
+	// CORS wraps the router so preflight requests are answered before routing and authentication
+	var handler http.Handler = middleware.CORSMiddleware(corsOptions(cfg.CORS))(r)
+	if cfg.Security.HTTPSRedirect {
+		// Orchestrators probe the health endpoints directly over HTTP
+		handler = middleware.HTTPSRedirectMiddleware(trustedProxies, "/healthz", "/readyz", "/startupz")(handler)
 	}
+	handler = middleware.SecurityHeadersMiddleware(securityHeaders)(handler)
+	handler = middleware.RealIPMiddleware(trustedProxies)(handler)
 
-	for pattern, config := range routes {
-		handler := config.Handler
-		if config.ApplyLogging {
-			handler = middleware.LoggingMiddleware(handler)
+	return handler, nil
+}
+
+// maxBodyBytes limits the request body size, requests exceeding it receive 413
+func maxBodyBytes(limit int64) router.Middleware {
+	return router.Named(fmt.Sprintf("max_body_bytes(%d)", limit), middleware.MaxBodyBytesMiddleware(limit))
+}
+
+// timeout bounds the handler's processing time, slower requests receive 504
+func timeout(d time.Duration) router.Middleware {
+	return router.Named(fmt.Sprintf("timeout(%s)", d), middleware.TimeoutMiddleware(d))
+}
+
+// securityHeadersOverride replaces the default security headers for a route
+func securityHeadersOverride(opts middleware.SecurityHeadersOptions) router.Middleware {
+	return router.Named("security_headers", middleware.SecurityHeadersMiddleware(opts))
+}
+
+// addAPIDocs documents the routes in an OpenAPI document served at /openapi.json, with a page
+// rendering it at /docs in development. Routes without documentation are an error so that
+// the document can't silently fall behind.
+func addAPIDocs(cfg *config.Config, r *router.Router, latestVersion string, deprecated map[string]bool, securityHeaders middleware.SecurityHeadersOptions, compression router.Middleware) error {
+	doc := openapi.New("go-web-dev-template API", latestVersion)
+	for _, route := range r.Routes() {
+		if route.Internal {
+			continue
+		}
+		if route.Doc == nil {
+			return fmt.Errorf("route %q has no OpenAPI documentation, call Document or MarkInternal", route.Pattern)
 		}
-		if config.ApplyJWT {
-			handler = middleware.ClerkAuthMiddleware(handler)
+		op := *route.Doc
+		op.Deprecated = deprecated[route.Version]
+		if err := doc.Add(route.Pattern, op, route.Uses("auth")); err != nil {
+			return fmt.Errorf("Failed to document route: %w", err)
 		}
-		mux.Handle(pattern, handler)
 	}
 
-	return mux
+	docHandler, err := doc.Handler()
+	if err != nil {
+		return fmt.Errorf("Failed to encode OpenAPI document: %w", err)
+	}
+	r.Handle("openapi", http.MethodGet, "/openapi.json", docHandler, compression).MarkInternal()
+
+	if cfg.Environment == config.Development {
+		docsSecurityHeaders := securityHeaders
+		docsSecurityHeaders.ContentSecurityPolicy = openapi.DocsContentSecurityPolicy
+		r.Handle("docs", http.MethodGet, "/docs", openapi.DocsHandler("/openapi.json", "/docs/"),
+			securityHeadersOverride(docsSecurityHeaders),
+		).MarkInternal()
+		r.Handle("docs.assets", http.MethodGet, "/docs/", http.StripPrefix("/docs/", openapi.DocsAssetsHandler()),
+			compression, securityHeadersOverride(docsSecurityHeaders),
+		).MarkInternal()
+	}
+	return nil
+}
+
+// apiRoutes registers the versioned API routes in groups, so that a new API version can share the
+// groups which haven't changed with the previous one
+type apiRoutes struct {
+	deps            Dependencies
+	dbPool          *pgxpool.Pool
+	webhookVerifier *handlers.WebhookVerifier
+	auth            router.Middleware
+	compression     router.Middleware
+	idempotency     router.Middleware
+}
+
+func (api apiRoutes) signup(v *router.Router) {
+	v.Handle("signup", http.MethodPost, "/signup", handlers.AddNewUser(api.dbPool),
+		timeout(10*time.Second), maxBodyBytes(16<<10), api.idempotency,
+	).Document(openapi.Operation{
+		Summary: "Sign up a user",
+		Description: "Send a unique Idempotency-Key header to retry safely: repeats receive the first response. " +
+			"Reusing a key for a different request is answered with 422, and with 409 while the first request is running.",
+		Tags:      []string{"users"},
+		Request:   requests.SignUpRequest{},
+		Responses: map[int]any{http.StatusCreated: nil},
+	})
+}
+
+func (api apiRoutes) webhooks(v *router.Router) {
+	v.Handle("webhooks.clerk", http.MethodPost, "/webhooks", handlers.ClerkWebhookHandler(api.dbPool, api.webhookVerifier),
+		timeout(15*time.Second), maxBodyBytes(1<<20),
+	).Document(openapi.Operation{
+		Summary:     "Receive a Clerk webhook event",
+		Description: "Deliveries are verified against the Svix signature headers.",
+		Tags:        []string{"webhooks"},
+		Request:     requests.ClerkWebhookEvent{},
+		Responses:   map[int]any{http.StatusOK: nil},
+	})
+}
+
+func (api apiRoutes) users(v *router.Router) {
+	authenticated := v.Group("", api.auth)
+	authenticated.Handle("me", http.MethodGet, "/me", handlers.GetCurrentUser(api.deps.DB),
+		api.compression, timeout(10*time.Second),
+	).Document(openapi.Operation{
+		Summary:     "Get the user the session token belongs to",
+		Description: "Responds with 304 when If-None-Match or If-Modified-Since show the client's copy is current.",
+		Tags:        []string{"users"},
+		Responses:   map[int]any{http.StatusOK: models.User{}, http.StatusNotModified: nil},
+	})
+
+	users := authenticated.Group("/users")
+	// Bounds its own time instead of using timeout, which buffers the response, so that exports
+	// are streamed
+	users.Handle("users.list", http.MethodGet, "", handlers.GetUsers(api.deps.DB, 10*time.Second, 10*time.Minute),
+		api.compression,
+	).Document(openapi.Operation{
+		Summary: "List users",
+		Description: "Users are listed as JSON, every user at once and streamed unless limit or after is set, in which " +
+			"case they are listed a page at a time and the next page is linked in the Link header. " +
+			"Exports in NDJSON (application/x-ndjson) or CSV (text/csv), selected with the Accept header or format, " +
+			"stream every remaining user unless limit is set.",
+		Tags: []string{"users"},
+		Query: []openapi.QueryParameter{
+			{Name: "format", Description: "json, ndjson or csv, overrides the Accept header", Type: ""},
+			{Name: "limit", Description: "Page size, 50 by default when after is set and at most 100 for JSON", Type: 0},
+			{Name: "after", Description: "Return users with IDs after this one", Type: int64(0)},
+		},
+		Responses: map[int]any{http.StatusOK: openapi.Content{
+			respond.JSON.ContentType:   []models.User{},
+			respond.NDJSON.ContentType: models.User{},
+			respond.CSV.ContentType:    "",
+		}},
+	})
+	users.Handle("users.get", http.MethodGet, "/{clerk_user_id}", handlers.GetUserByClerkUserId(api.deps.DB),
+		api.compression, timeout(10*time.Second),
+	).Document(openapi.Operation{
+		Summary:     "Get a user by their Clerk user ID",
+		Description: "Responds with 304 when If-None-Match or If-Modified-Since show the client's copy is current.",
+		Tags:        []string{"users"},
+		Responses:   map[int]any{http.StatusOK: models.User{}, http.StatusNotModified: nil},
+	})
+	users.Handle("users.delete", http.MethodDelete, "/{id}", handlers.DeleteUserByID(api.dbPool),
+		timeout(10*time.Second),
+	).Document(openapi.Operation{
+		Summary:     "Delete a user",
+		Description: "With If-Match or If-Unmodified-Since the user is only deleted if it hasn't changed, otherwise 412 is returned.",
+		Tags:        []string{"users"},
+		Responses:   map[int]any{http.StatusNoContent: nil},
+	})
 }
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"net/http"
+	"time"
+)
+
+// IdempotencyKey records the first request made with an idempotency key and, once it has
+// completed, the response replayed for repeats of the request
+type IdempotencyKey struct {
+	Scope       string      `json:"scope"`
+	Key         string      `json:"key"`
+	Fingerprint string      `json:"fingerprint"`
+	StatusCode  *int        `json:"status_code"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+	CreatedAt   time.Time   `json:"created_at"`
+	ExpiresAt   time.Time   `json:"expires_at"`
+}
+
+// Completed reports whether the first request has finished and its response has been stored
+func (k IdempotencyKey) Completed() bool {
+	return k.StatusCode != nil
+}
@@ -1,9 +1,29 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type User struct {
-	ID        int       `json:"id"`
-	ClerkID   string    `json:"clerk_id"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        int        `json:"id"`
+	ClerkID   string     `json:"clerk_id"`
+	Email     *string    `json:"email"`
+	FirstName *string    `json:"first_name"`
+	LastName  *string    `json:"last_name"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// LastModified is when the user was last updated, or created if it never has been
+func (u User) LastModified() time.Time {
+	if u.UpdatedAt != nil {
+		return *u.UpdatedAt
+	}
+	return u.CreatedAt
+}
+
+// ETag identifies the version of the user, it changes whenever the row is updated
+func (u User) ETag() string {
+	return fmt.Sprintf(`"%d-%x"`, u.ID, u.LastModified().UnixMicro())
 }
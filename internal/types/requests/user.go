@@ -0,0 +1,9 @@
+package requests
+
+// SignUpRequest is the body of POST /v1/signup
+type SignUpRequest struct {
+	ClerkID   string  `json:"clerk_id" validate:"required,max=255"`
+	Email     *string `json:"email" validate:"email,max=255"`
+	FirstName *string `json:"first_name" validate:"max=255"`
+	LastName  *string `json:"last_name" validate:"max=255"`
+}
@@ -0,0 +1,9 @@
+package requests
+
+import "encoding/json"
+
+// ClerkWebhookEvent is the body of POST /v1/webhooks, Data depends on the event type
+type ClerkWebhookEvent struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
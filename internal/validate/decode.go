@@ -0,0 +1,78 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/respond"
+)
+
+// DecodeJSON decodes the request body into dst and validates it with Struct. Bodies that aren't a
+// single JSON value, contain unknown fields or have values of the wrong type are rejected with a
+// 400 problem, bodies over the MaxBodyBytesMiddleware limit with a 413, and invalid fields with a
+// 422 listing every one of them. The returned error can be passed straight to respond.Error.
+func DecodeJSON(r *http.Request, dst any) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(dst); err != nil {
+		return decodeProblem(err)
+	}
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		return respond.NewProblem(http.StatusBadRequest, "Request body must contain a single JSON value")
+	}
+
+	if errs := Struct(dst); len(errs) > 0 {
+		problem := respond.NewProblem(http.StatusUnprocessableEntity, "Request body has invalid fields")
+		problem.Errors = errs
+		return problem
+	}
+	return nil
+}
+
+func decodeProblem(err error) *respond.Problem {
+	var maxBytesErr *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return respond.NewProblem(http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit))
+	case errors.Is(err, io.EOF):
+		return respond.NewProblem(http.StatusBadRequest, "Request body must not be empty")
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return respond.NewProblem(http.StatusBadRequest, "Request body must be valid JSON")
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		problem := respond.NewProblem(http.StatusBadRequest, "Request body has fields of the wrong type")
+		problem.Errors = []respond.FieldError{{Field: typeErr.Field, Message: "must be " + jsonType(typeErr.Type.Kind().String())}}
+		return problem
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json doesn't export an error type for unknown fields
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		problem := respond.NewProblem(http.StatusBadRequest, "Request body has unknown fields")
+		problem.Errors = []respond.FieldError{{Field: field, Message: "is not allowed"}}
+		return problem
+	default:
+		return respond.NewProblem(http.StatusBadRequest, "Request body must be a JSON object")
+	}
+}
+
+// jsonType describes a Go kind in JSON terms for error messages
+func jsonType(kind string) string {
+	switch {
+	case kind == "string":
+		return "a string"
+	case kind == "bool":
+		return "a boolean"
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "a number"
+	case kind == "slice", kind == "array":
+		return "an array"
+	default:
+		return "an object"
+	}
+}
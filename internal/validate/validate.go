@@ -0,0 +1,182 @@
+// Package validate decodes JSON request bodies and checks them against the rules declared in
+// `validate` struct tags and an optional Validate method, reporting every invalid field at once.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/respond"
+)
+
+// Validator is implemented by request types with rules that can't be expressed in tags, such as
+// ones spanning several fields. Validate runs after the tag rules and its errors are added to theirs.
+type Validator interface {
+	Validate() []respond.FieldError
+}
+
+// Struct checks v, a struct or pointer to one, against its `validate` tags and returns an error for
+// each invalid field, named by its JSON path. The supported rules, separated by commas, are:
+//
+//	required    the value must not be the zero value, or nil for pointers, slices and maps
+//	min=N       strings must have at least N characters, slices at least N items, numbers be >= N
+//	max=N       strings must have at most N characters, slices at most N items, numbers be <= N
+//	email       strings must be a single email address
+//	oneof=a b   strings must be one of the space separated values
+//
+// Rules other than required are skipped for zero values and nil pointers, so optional fields are
+// only checked when present. Nested structs and slices of structs are validated recursively.
+func Struct(v any) []respond.FieldError {
+	var errs []respond.FieldError
+	validateValue(reflect.ValueOf(v), "", &errs)
+	return errs
+}
+
+func validateValue(v reflect.Value, path string, errs *[]respond.FieldError) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := jsonName(field)
+			if name == "-" {
+				continue
+			}
+			fieldPath := name
+			if path != "" {
+				fieldPath = path + "." + name
+			}
+
+			fieldValue := v.Field(i)
+			if message := checkRules(fieldValue, field.Tag.Get("validate")); message != "" {
+				*errs = append(*errs, respond.FieldError{Field: fieldPath, Message: message})
+				continue
+			}
+			validateValue(fieldValue, fieldPath, errs)
+		}
+
+		var validator Validator
+		var ok bool
+		if v.CanAddr() {
+			validator, ok = v.Addr().Interface().(Validator)
+		} else {
+			validator, ok = v.Interface().(Validator)
+		}
+		if ok {
+			for _, err := range validator.Validate() {
+				if path != "" {
+					err.Field = path + "." + err.Field
+				}
+				*errs = append(*errs, err)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+// checkRules returns the message for the first rule the value breaks, or "" if it satisfies them all
+func checkRules(v reflect.Value, tag string) string {
+	if tag == "" {
+		return ""
+	}
+
+	rules := strings.Split(tag, ",")
+	if v.IsZero() {
+		if slices.Contains(rules, "required") {
+			return "is required"
+		}
+		return ""
+	}
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	for _, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		var message string
+		switch name {
+		case "required":
+		case "min", "max":
+			message = checkBound(v, name, param)
+		case "email":
+			if address, err := mail.ParseAddress(v.String()); err != nil || address.Address != v.String() {
+				message = "must be a valid email address"
+			}
+		case "oneof":
+			if !slices.Contains(strings.Fields(param), v.String()) {
+				message = "must be one of " + strings.Join(strings.Fields(param), ", ")
+			}
+		default:
+			panic(fmt.Sprintf("validate: unknown rule %q", name))
+		}
+		if message != "" {
+			return message
+		}
+	}
+	return ""
+}
+
+func checkBound(v reflect.Value, rule, param string) string {
+	bound, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validate: invalid %s parameter %q", rule, param))
+	}
+
+	var n float64
+	var unit string
+	switch v.Kind() {
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, unit = float64(v.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	default:
+		panic(fmt.Sprintf("validate: %s is not supported for %s", rule, v.Kind()))
+	}
+
+	if rule == "min" && n < bound {
+		if unit == "" {
+			return "must be at least " + param
+		}
+		return "must have at least " + param + unit
+	}
+	if rule == "max" && n > bound {
+		if unit == "" {
+			return "must be at most " + param
+		}
+		return "must have at most " + param + unit
+	}
+	return ""
+}
+
+// jsonName returns the name the field is encoded with, the same as encoding/json
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
@@ -2,101 +2,65 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log/slog"
-	"net"
-	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
+	"strings"
 	"time"
 
-	"github.com/anishsharma21/go-web-dev-template/internal"
+	"github.com/anishsharma21/go-web-dev-template/internal/config"
+	"github.com/anishsharma21/go-web-dev-template/internal/lifecycle"
 	"github.com/anishsharma21/go-web-dev-template/internal/middleware"
-	"github.com/anishsharma21/go-web-dev-template/internal/setup"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-var dbConnStr string
-var dbPool *pgxpool.Pool
-
 func init() {
-	dbConnStr = os.Getenv(internal.DATABASE_URL)
-	if dbConnStr == "" {
-		slog.Error("DATABASE_URL environment variable not set")
-		os.Exit(1)
-	}
-
 	defaultLogHandler := slog.NewJSONHandler(os.Stdout, nil)
 	slog.SetDefault(slog.New(&middleware.CustomLogHandler{Handler: defaultLogHandler}))
 }
 
-func main() {
-	// Setup context for graceful shutdown
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+const usage = `Usage:
+  main [serve] [flags]        start the HTTP server (default)
+  main migrate [flags] <cmd>  manage database migrations, see "main migrate help"`
 
-	dbPool, err := setup.DBPool(ctx, dbConnStr)
-	if err != nil {
-		slog.Error("Failed to initialise database connection pool", "error", err)
-		return
+func main() {
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
-	defer dbPool.Close()
 
-	if os.Getenv(internal.RUN_MIGRATION) == "true" {
-		slog.Info("Attempting to run database migrations...")
-		err := setup.Migrations(dbConnStr)
-		if err != nil {
-			slog.Error("Failed to run database migrations", "error", err)
-			return
+	switch command {
+	case "serve":
+		serve(args)
+	case "migrate":
+		if err := migrate(args); err != nil {
+			slog.Error("Migration command failed", "error", err)
+			os.Exit(1)
 		}
-		slog.Info("Database migrations complete.")
-	} else {
-		slog.Info("Database migrations skipped.")
-	}
-
-	port := os.Getenv(internal.PORT)
-	if port == "" {
-		port = "8080"
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
 	}
+}
 
-	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: setup.Routes(dbPool),
-		BaseContext: func(l net.Listener) context.Context {
-			url := "http://" + l.Addr().String()
-			slog.Info(fmt.Sprintf("Server started on %s", url))
-			return ctx
-		},
+// serve runs the HTTP server until it receives SIGINT or SIGTERM. Components start in dependency
+// order and stop in reverse, a second signal exits without waiting.
+func serve(args []string) {
+	cfg, err := config.Load(args)
+	if err != nil {
+		slog.Error("Failed to load configuration", "error", err)
+		os.Exit(1)
 	}
 
-	shutdownChan := make(chan bool, 1)
-
-	// Start server in a goroutine
-	go func() {
-		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
-			slog.Error("HTTP server closed early", "error", err)
-		}
-		slog.Info("Stopped serving new connections.")
-		shutdownChan <- true
-	}()
+	c := &components{}
+	app := lifecycle.New(10 * time.Second)
+	app.Append(databaseHook(cfg, c))
+	app.Append(httpHook(cfg, c, app))
+	app.Append(migrationsHook(cfg, c))
+	app.Append(backfillsHook(cfg, c))
 
-	// Listen for OS signals (SIGINT, SIGTERM) to shutdown server gracefully
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	sig := <-sigChan
-	slog.Warn("Received signal", "signal", sig.String())
-
-	// Shutdown server gracefully within 10 seconds
-	shutdownCtx, shutdownRelease := context.WithTimeout(ctx, 10*time.Second)
-	defer shutdownRelease()
-
-	if err := server.Shutdown(shutdownCtx); err != nil {
-		slog.Error("HTTP shutdown error occurred", "error", err)
+	if err := app.Run(context.Background()); err != nil {
+		slog.Error("Server stopped after an error", "error", err)
+		os.Exit(1)
 	}
-	<-shutdownChan
-	close(shutdownChan)
-
 	slog.Info("Graceful server shutdown complete.")
 }
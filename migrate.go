@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/config"
+	"github.com/anishsharma21/go-web-dev-template/internal/setup"
+	"github.com/pressly/goose/v3"
+)
+
+const migrateUsage = `Usage: main migrate [flags] <command>
+
+Commands:
+  up                                 apply all pending migrations
+  down                               roll back the most recent migration
+  status                             list migrations and whether they have been applied
+  redo                               roll back the most recent migration and apply it again
+  to <version>                       migrate up or down to a version
+  create [-dir dir] <name> [sql|go]  create a new migration file (default sql)
+
+Flags are the same as for serve, e.g. -config and -env, and must come before the command.`
+
+// migrate runs a migration command using the migrations embedded in the binary
+func migrate(args []string) error {
+	// create only writes a file, so it doesn't need any configuration or a database
+	if len(args) > 0 && args[0] == "create" {
+		return createMigration(args[1:])
+	}
+	if len(args) == 0 || args[0] == "help" {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return nil
+	}
+
+	cfg, args, err := config.LoadDatabase(args)
+	if err != nil {
+		return err
+	}
+	command, err := setup.ParseMigrationCommand(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	dbPool, err := setup.DBPool(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer dbPool.Close()
+
+	migrator, err := setup.NewMigrator(dbPool)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	if command.Name == "status" {
+		return printMigrationStatus(ctx, migrator)
+	}
+
+	// Take the same lock as servers migrating on startup, so they never run at the same time
+	return migrator.WithLock(ctx, cfg.Migrations.LockTimeout, cfg.Migrations.LockPollInterval, func(ctx context.Context) error {
+		return migrator.Run(ctx, command)
+	})
+}
+
+func printMigrationStatus(ctx context.Context, migrator *setup.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to retrieve status of migrations: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "APPLIED AT\tMIGRATION")
+	for _, status := range statuses {
+		appliedAt := "Pending"
+		if status.State == goose.StateApplied {
+			appliedAt = status.AppliedAt.UTC().Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", appliedAt, status.Source.Path)
+	}
+	return w.Flush()
+}
+
+// createMigration writes a new timestamped migration file to the migrations directory
+func createMigration(args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	dir := fs.String("dir", "migrations", "directory to create the migration in")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	args = fs.Args()
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New("Usage: main migrate create [-dir dir] <name> [sql|go]")
+	}
+	migrationType := "sql"
+	if len(args) == 2 {
+		migrationType = args[1]
+	}
+
+	if err := setup.CreateMigration(*dir, args[0], migrationType); err != nil {
+		return err
+	}
+	slog.Info("Created migration, rebuild the binary to embed it", "dir", *dir, "name", args[0])
+	return nil
+}
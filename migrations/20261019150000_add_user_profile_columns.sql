@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN email VARCHAR(255),
+    ADD COLUMN first_name VARCHAR(255),
+    ADD COLUMN last_name VARCHAR(255),
+    ADD COLUMN updated_at TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+    DROP COLUMN email,
+    DROP COLUMN first_name,
+    DROP COLUMN last_name,
+    DROP COLUMN updated_at;
+-- +goose StatementEnd
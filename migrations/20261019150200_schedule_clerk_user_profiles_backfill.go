@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/backfill"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upScheduleClerkUserProfilesBackfill, downScheduleClerkUserProfilesBackfill)
+}
+
+// Existing users only have a Clerk ID, so their email and name are copied from Clerk in the
+// background rather than while the deploy waits on this migration
+func upScheduleClerkUserProfilesBackfill(ctx context.Context, tx *sql.Tx) error {
+	return backfill.Schedule(ctx, tx, backfill.ClerkUserProfilesJob)
+}
+
+func downScheduleClerkUserProfilesBackfill(ctx context.Context, tx *sql.Tx) error {
+	return backfill.Unschedule(ctx, tx, backfill.ClerkUserProfilesJob)
+}
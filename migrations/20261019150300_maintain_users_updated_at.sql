@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- updated_at is the version the ETag and Last-Modified of user responses are derived from, so
+-- every update must advance it, not only those which remember to set it
+CREATE FUNCTION set_updated_at() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER users_set_updated_at
+    BEFORE UPDATE ON users
+    FOR EACH ROW
+    EXECUTE FUNCTION set_updated_at();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER users_set_updated_at ON users;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP FUNCTION set_updated_at();
+-- +goose StatementEnd
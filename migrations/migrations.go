@@ -0,0 +1,11 @@
+// Package migrations holds the database migrations, which are built into the binary so they can
+// be run without the migrations directory or the goose CLI. SQL migrations are embedded, Go
+// migrations register themselves with goose when this package is imported.
+package migrations
+
+import "embed"
+
+// FS contains the SQL migrations
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/apiversion"
+	"github.com/anishsharma21/go-web-dev-template/internal/db"
+	"github.com/anishsharma21/go-web-dev-template/internal/handlers"
+	"github.com/anishsharma21/go-web-dev-template/internal/router"
+)
+
+func TestAPIVersionsShareHandlersAndAnnounceDeprecation(t *testing.T) {
+	// Arrange
+	deprecated := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	versions := []apiversion.Version{
+		{Name: "v1", Deprecated: deprecated, Sunset: sunset, MigrationGuide: "https://example.com/v2"},
+		{Name: "v2"},
+	}
+
+	var handledVersions []string
+	shared := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handledVersions = append(handledVersions, apiversion.FromContext(r.Context()))
+	})
+
+	usage := apiversion.NewUsage()
+	r := router.New()
+	for _, version := range versions {
+		group := r.Version(version.Name, router.Named("api_version", usage.Middleware(version)))
+		group.Handle("users.list", http.MethodGet, "/users", shared)
+	}
+
+	// Act
+	v1 := httptest.NewRecorder()
+	r.ServeHTTP(v1, httptest.NewRequest(http.MethodGet, "/v1/users", nil))
+	v2 := httptest.NewRecorder()
+	r.ServeHTTP(v2, httptest.NewRequest(http.MethodGet, "/v2/users", nil))
+
+	// Assert
+	if len(handledVersions) != 2 || handledVersions[0] != "v1" || handledVersions[1] != "v2" {
+		t.Errorf("Expected the shared handler to see each version, got %v\n", handledVersions)
+	}
+	if got := v1.Header().Get("Deprecation"); got != "@1767225600" {
+		t.Errorf("Expected Deprecation header for v1, got %q\n", got)
+	}
+	if got := v1.Header().Get("Sunset"); got != "Fri, 01 Jan 2027 00:00:00 GMT" {
+		t.Errorf("Expected Sunset header for v1, got %q\n", got)
+	}
+	if v2.Header().Get("Deprecation") != "" || v2.Header().Get("Sunset") != "" {
+		t.Errorf("Expected no deprecation headers for v2, got %v\n", v2.Header())
+	}
+	if r.Lookup("v1.users.list") == nil || r.Lookup("v2.users.list") == nil {
+		t.Errorf("Expected route names to be prefixed with their version, got %+v\n", r.Routes())
+	}
+	if counts := usage.Snapshot(); counts["v1"] != 1 || counts["v2"] != 1 {
+		t.Errorf("Expected one request counted for each version, got %v\n", counts)
+	}
+}
+
+func TestDeprecatedVersionKeepsDeprecationLinkOnPaginatedList(t *testing.T) {
+	// Arrange
+	clerkIDs := []string{"testclerkid_page_1", "testclerkid_page_2"}
+	for _, clerkID := range clerkIDs {
+		if _, err := dbPool.Exec(ctx, "INSERT INTO users (clerk_id) VALUES ($1)", clerkID); err != nil {
+			t.Fatalf("Failed to insert user: %v", err)
+		}
+	}
+	defer dbPool.Exec(ctx, "DELETE FROM users WHERE clerk_id = ANY($1)", clerkIDs)
+
+	v1 := apiversion.Version{Name: "v1", Deprecated: time.Now().Add(-time.Hour), MigrationGuide: "https://example.com/v2"}
+	r := router.New()
+	group := r.Version(v1.Name, router.Named("api_version", apiversion.NewUsage().Middleware(v1)))
+	group.Handle("users.list", http.MethodGet, "/users", handlers.GetUsers(db.NewRouter(dbPool, nil, 0), 10*time.Second, time.Minute))
+	w := httptest.NewRecorder()
+
+	// Act
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/users?limit=1", nil))
+
+	// Assert
+	links := w.Header().Values("Link")
+	hasLink := func(rel string) bool {
+		return slices.ContainsFunc(links, func(link string) bool { return strings.Contains(link, `rel="`+rel+`"`) })
+	}
+	if w.Code != http.StatusOK || !hasLink("deprecation") || !hasLink("next") {
+		t.Errorf("Expected status 200 with deprecation and next links, got %v %q\n", w.Code, links)
+	}
+}
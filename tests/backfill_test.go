@@ -0,0 +1,216 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/backfill"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// newBackfillTable creates a table of rows to backfill and schedules a job for it, both removed
+// when the test ends
+func newBackfillTable(t *testing.T, name string, rows int) []int64 {
+	t.Helper()
+	cleanup := func() {
+		dbPool.Exec(context.Background(), "DROP TABLE IF EXISTS "+pgx.Identifier{name}.Sanitize())
+		dbPool.Exec(context.Background(), "DELETE FROM backfill_progress WHERE name = $1", name)
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	if _, err := dbPool.Exec(ctx, "CREATE TABLE "+pgx.Identifier{name}.Sanitize()+" (id BIGSERIAL PRIMARY KEY, backfilled BOOLEAN NOT NULL DEFAULT false)"); err != nil {
+		t.Fatalf("Failed to create backfill table: %v", err)
+	}
+	var ids []int64
+	for range rows {
+		var id int64
+		if err := dbPool.QueryRow(ctx, "INSERT INTO "+pgx.Identifier{name}.Sanitize()+" DEFAULT VALUES RETURNING id").Scan(&id); err != nil {
+			t.Fatalf("Failed to insert backfill row: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if _, err := dbPool.Exec(ctx, "INSERT INTO backfill_progress (name) VALUES ($1)", name); err != nil {
+		t.Fatalf("Failed to schedule backfill: %v", err)
+	}
+	return ids
+}
+
+// recordingJob backfills the table's rows and records the ids of each batch it processes. A
+// batch fails, after updating its rows, when fail returns true for it.
+type recordingJob struct {
+	mu      sync.Mutex
+	batches [][]int64
+	fail    func(ids []int64) bool
+}
+
+func (j *recordingJob) job(name string, batchSize int) backfill.Job {
+	return backfill.Job{
+		Name:      name,
+		Table:     name,
+		BatchSize: batchSize,
+		Process: func(ctx context.Context, tx pgx.Tx, ids []int64, prepared any) error {
+			if _, err := tx.Exec(ctx, "UPDATE "+pgx.Identifier{name}.Sanitize()+" SET backfilled = true WHERE id = ANY($1)", ids); err != nil {
+				return err
+			}
+			if j.fail != nil && j.fail(ids) {
+				return errors.New("batch failed")
+			}
+			j.mu.Lock()
+			j.batches = append(j.batches, ids)
+			j.mu.Unlock()
+			return nil
+		},
+	}
+}
+
+func (j *recordingJob) processed() []int64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return slices.Concat(j.batches...)
+}
+
+type backfillProgress struct {
+	lastID        int64
+	rowsProcessed int64
+	completed     bool
+}
+
+func readBackfillProgress(t *testing.T, name string) backfillProgress {
+	t.Helper()
+	var progress backfillProgress
+	var completedAt *time.Time
+	err := dbPool.QueryRow(ctx, "SELECT last_id, rows_processed, completed_at FROM backfill_progress WHERE name = $1", name).
+		Scan(&progress.lastID, &progress.rowsProcessed, &completedAt)
+	if err != nil {
+		t.Fatalf("Failed to read backfill progress: %v", err)
+	}
+	progress.completed = completedAt != nil
+	return progress
+}
+
+func backfilledIDs(t *testing.T, name string) []int64 {
+	t.Helper()
+	rows, err := dbPool.Query(ctx, "SELECT id FROM "+pgx.Identifier{name}.Sanitize()+" WHERE backfilled ORDER BY id")
+	if err != nil {
+		t.Fatalf("Failed to read backfilled rows: %v", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		t.Fatalf("Failed to read backfilled rows: %v", err)
+	}
+	return ids
+}
+
+func TestBackfillCommitsProgressWithEachBatchAndResumes(t *testing.T) {
+	// Arrange
+	name := "backfill_test_resume"
+	ids := newBackfillTable(t, name, 5)
+	// The instance stops after its second batch, as if it had been restarted
+	first := &recordingJob{fail: func(batch []int64) bool { return batch[0] == ids[4] }}
+
+	// Act
+	backfill.NewRunner(dbPool).Run(ctx, first.job(name, 2))
+	interrupted := readBackfillProgress(t, name)
+	second := &recordingJob{}
+	backfill.NewRunner(dbPool).Run(ctx, second.job(name, 2))
+
+	// Assert
+	if interrupted.lastID != ids[3] || interrupted.rowsProcessed != 4 || interrupted.completed {
+		t.Errorf("Expected progress up to id %d after two batches, got %+v\n", ids[3], interrupted)
+	}
+	if !slices.Equal(first.processed(), ids[:4]) {
+		t.Errorf("Expected the first run to process %v, got %v\n", ids[:4], first.processed())
+	}
+	if !slices.Equal(second.processed(), ids[4:]) {
+		t.Errorf("Expected the restarted run to resume at %v, got %v\n", ids[4:], second.processed())
+	}
+}
+
+func TestBackfillMarksCompletedJobs(t *testing.T) {
+	// Arrange
+	name := "backfill_test_complete"
+	ids := newBackfillTable(t, name, 3)
+	job := &recordingJob{}
+
+	// Act
+	backfill.NewRunner(dbPool).Run(ctx, job.job(name, 2))
+	progress := readBackfillProgress(t, name)
+	// New rows after completion aren't the backfill's concern, they are written in the new format
+	if _, err := dbPool.Exec(ctx, "INSERT INTO "+pgx.Identifier{name}.Sanitize()+" DEFAULT VALUES"); err != nil {
+		t.Fatalf("Failed to insert row: %v", err)
+	}
+	backfill.NewRunner(dbPool).Run(ctx, job.job(name, 2))
+
+	// Assert
+	if !progress.completed || progress.lastID != ids[2] || progress.rowsProcessed != 3 {
+		t.Errorf("Expected the job to be complete after all 3 rows, got %+v\n", progress)
+	}
+	if !slices.Equal(job.processed(), ids) {
+		t.Errorf("Expected a completed job not to run again, processed %v\n", job.processed())
+	}
+}
+
+func TestBackfillRunnersTakeTurnsWithoutRepeatingBatches(t *testing.T) {
+	// Arrange
+	name := "backfill_test_concurrent"
+	ids := newBackfillTable(t, name, 20)
+	job := &recordingJob{}
+	slowJob := job.job(name, 3)
+	process := slowJob.Process
+	// Slow batches make the runners wait for each other on the progress row
+	slowJob.Process = func(ctx context.Context, tx pgx.Tx, ids []int64, prepared any) error {
+		time.Sleep(10 * time.Millisecond)
+		return process(ctx, tx, ids, prepared)
+	}
+
+	// Act
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool, err := pgxpool.NewWithConfig(ctx, dbPool.Config())
+			if err != nil {
+				t.Errorf("Failed to create pool: %v", err)
+				return
+			}
+			defer pool.Close()
+			backfill.NewRunner(pool).Run(ctx, slowJob)
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	processed := job.processed()
+	slices.Sort(processed)
+	if !slices.Equal(processed, ids) {
+		t.Errorf("Expected every row to be processed exactly once, got %v\n", processed)
+	}
+	if progress := readBackfillProgress(t, name); !progress.completed || progress.rowsProcessed != int64(len(ids)) {
+		t.Errorf("Expected the job to be complete with %d rows, got %+v\n", len(ids), progress)
+	}
+}
+
+func TestBackfillRollsBackFailedBatches(t *testing.T) {
+	// Arrange
+	name := "backfill_test_rollback"
+	ids := newBackfillTable(t, name, 4)
+	job := &recordingJob{fail: func(batch []int64) bool { return batch[0] == ids[2] }}
+
+	// Act
+	backfill.NewRunner(dbPool).Run(ctx, job.job(name, 2))
+
+	// Assert
+	if progress := readBackfillProgress(t, name); progress.lastID != ids[1] || progress.rowsProcessed != 2 || progress.completed {
+		t.Errorf("Expected the failed batch's progress not to be recorded, got %+v\n", progress)
+	}
+	if backfilled := backfilledIDs(t, name); !slices.Equal(backfilled, ids[:2]) {
+		t.Errorf("Expected the failed batch's updates to be rolled back, got %v backfilled\n", backfilled)
+	}
+}
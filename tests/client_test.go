@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/anishsharma21/go-web-dev-template/client"
+	"github.com/anishsharma21/go-web-dev-template/internal/respond"
+)
+
+func TestClientOnlyDependsOnTheStandardLibrary(t *testing.T) {
+	// Act
+	out, err := exec.Command("go", "list", "-deps", "-f", "{{if not .Standard}}{{.ImportPath}}{{end}}", "../client").Output()
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Failed to list the client's dependencies: %v", err)
+	}
+	allowed := []string{"github.com/anishsharma21/go-web-dev-template/client", "github.com/anishsharma21/go-web-dev-template/internal/retry"}
+	for _, dependency := range strings.Fields(string(out)) {
+		if !slices.Contains(allowed, dependency) {
+			t.Errorf("Expected the client not to import %s, services using it would pull in server dependencies\n", dependency)
+		}
+	}
+}
+
+func TestClientCoversEveryRoute(t *testing.T) {
+	// Arrange
+	handler := testRoutes(t)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Tags []string `json:"tags"`
+		} `json:"paths"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("Failed to decode OpenAPI document: %v", err)
+	}
+
+	// Act
+	var routes []string
+	for path, operations := range doc.Paths {
+		for method, operation := range operations {
+			// Webhooks are called by Clerk rather than by other services
+			if strings.HasPrefix(path, "/v1/") && !slices.Contains(operation.Tags, "webhooks") {
+				routes = append(routes, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+
+	// Assert
+	slices.Sort(routes)
+	endpoints := slices.Sorted(slices.Values(client.Endpoints))
+	if !slices.Equal(routes, endpoints) {
+		t.Errorf("Expected client endpoints to match the API routes %v, got %v\n", routes, endpoints)
+	}
+}
+
+func TestClientListUsersFollowsPagination(t *testing.T) {
+	// Arrange
+	var authorization atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization.Store(r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("after") {
+		case "":
+			if limit := r.URL.Query().Get("limit"); limit != "2" {
+				t.Errorf("Expected the first page to be requested with the page size, got limit %q\n", limit)
+			}
+			w.Header().Set("Link", `</v1/users?after=2&limit=2>; rel="next"`)
+			w.Write([]byte(`[{"id": 1, "clerk_id": "user_1"}, {"id": 2, "clerk_id": "user_2"}]`))
+		case "2":
+			w.Write([]byte(`[{"id": 3, "clerk_id": "user_3"}]`))
+		default:
+			t.Errorf("Expected no request after the last page, got %s\n", r.URL)
+		}
+	}))
+	defer server.Close()
+
+	c, err := client.New(server.URL, client.WithToken("session-token"))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	// Act
+	var clerkIDs []string
+	for user, err := range c.ListUsers(context.Background(), &client.ListUsersOptions{PageSize: 2}) {
+		if err != nil {
+			t.Fatalf("Expected no error listing users, got %v\n", err)
+		}
+		clerkIDs = append(clerkIDs, user.ClerkID)
+	}
+
+	// Assert
+	if strings.Join(clerkIDs, ",") != "user_1,user_2,user_3" {
+		t.Errorf("Expected users from both pages, got %v\n", clerkIDs)
+	}
+	if authorization.Load() != "Bearer session-token" {
+		t.Errorf("Expected bearer token to be sent, got %q\n", authorization.Load())
+	}
+}
+
+func TestClientRetriesIdempotentCallsAndDecodesProblems(t *testing.T) {
+	// Arrange
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			respond.Problemf(w, r, http.StatusServiceUnavailable, "Request timed out")
+			return
+		}
+		respond.Problemf(w, r, http.StatusNotFound, "user not found")
+	}))
+	defer server.Close()
+
+	c, err := client.New(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	// Act
+	_, err = c.GetUser(context.Background(), "user_missing")
+
+	// Assert
+	if attempts.Load() != 2 {
+		t.Errorf("Expected the 503 to be retried once, got %d attempts\n", attempts.Load())
+	}
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("Expected a not found *client.Error, got %v\n", err)
+	}
+	if apiErr.Detail != "user not found" || apiErr.Instance != "/v1/users/user_missing" {
+		t.Errorf("Expected problem details to be decoded, got %+v\n", apiErr)
+	}
+}
+
+func TestClientRejectsUnboundedRetries(t *testing.T) {
+	for _, maxAttempts := range []int{0, -1} {
+		// Act
+		_, err := client.New("https://api.example.com", client.WithMaxAttempts(maxAttempts))
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error for %d max attempts, got nil\n", maxAttempts)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/middleware"
+)
+
+func TestCompressionNegotiatesEncoding(t *testing.T) {
+	body := strings.Repeat(`{"clerk_id": "user_123"}`, 100)
+	tests := []struct {
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br, zstd", "zstd"},
+		{"gzip;q=0.5, br;q=0.8", "br"},
+		{"GZIP;Q=0.5", "gzip"},
+		{"*", "zstd"},
+		{"*;q=0.5, gzip", "gzip"},
+		{"zstd;q=0, *", "br"},
+		{"gzip;q=0", ""},
+		{"identity;q=0", ""},
+		{"identity;q=0, gzip;q=0.1", "gzip"},
+		{"*;q=0", ""},
+		{"deflate", ""},
+		{"gzip;q=invalid", ""},
+	}
+
+	for _, test := range tests {
+		// Arrange
+		handler := middleware.CompressionMiddleware(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		}))
+		r := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+		if test.acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", test.acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+
+		// Act
+		handler.ServeHTTP(w, r)
+
+		// Assert
+		if encoding := w.Header().Get("Content-Encoding"); encoding != test.wantEncoding {
+			t.Errorf("Expected encoding %q for Accept-Encoding %q, got %q\n", test.wantEncoding, test.acceptEncoding, encoding)
+		}
+		if test.wantEncoding == "" && w.Body.String() != body {
+			t.Errorf("Expected the body as is for Accept-Encoding %q\n", test.acceptEncoding)
+		}
+		if !slices.Contains(w.Header().Values("Vary"), "Accept-Encoding") {
+			t.Errorf("Expected Vary: Accept-Encoding for Accept-Encoding %q, got %v\n", test.acceptEncoding, w.Header().Values("Vary"))
+		}
+	}
+}
+
+func TestCompressionSkipsSmallAndCompressedBodies(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentType  string
+		size         int
+		wantEncoding string
+	}{
+		{"large JSON", "application/json", 1024, "gzip"},
+		{"small JSON", "application/json", 1023, ""},
+		{"empty body", "application/json", 0, ""},
+		{"PNG image", "image/png", 4096, ""},
+		{"gzip archive", "application/gzip", 4096, ""},
+		{"SVG image", "image/svg+xml", 4096, "gzip"},
+	}
+
+	for _, test := range tests {
+		// Arrange
+		body := strings.Repeat("a", test.size)
+		handler := middleware.CompressionMiddleware(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", test.contentType)
+			// Write in two parts so the threshold is crossed across writes
+			w.Write([]byte(body[:test.size/2]))
+			w.Write([]byte(body[test.size/2:]))
+		}))
+		r := httptest.NewRequest(http.MethodGet, "/static/file", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+
+		// Act
+		handler.ServeHTTP(w, r)
+
+		// Assert
+		if encoding := w.Header().Get("Content-Encoding"); encoding != test.wantEncoding {
+			t.Errorf("%s: expected encoding %q, got %q\n", test.name, test.wantEncoding, encoding)
+		}
+		got := w.Body.String()
+		if test.wantEncoding == "gzip" {
+			gz, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatalf("%s: failed to read gzip body: %v", test.name, err)
+			}
+			decoded, err := io.ReadAll(gz)
+			if err != nil {
+				t.Fatalf("%s: failed to decompress body: %v", test.name, err)
+			}
+			got = string(decoded)
+		}
+		if got != body {
+			t.Errorf("%s: expected the %d byte body to round trip, got %d bytes\n", test.name, test.size, len(got))
+		}
+		if !slices.Contains(w.Header().Values("Vary"), "Accept-Encoding") {
+			t.Errorf("%s: expected Vary: Accept-Encoding, got %v\n", test.name, w.Header().Values("Vary"))
+		}
+	}
+}
+
+func TestCompressionFlushesSmallChunks(t *testing.T) {
+	// Arrange
+	w := httptest.NewRecorder()
+	var flushedBytes int
+	handler := middleware.CompressionMiddleware(1024)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/x-ndjson")
+		rw.Write([]byte(`{"id": 1}` + "\n"))
+		if err := http.NewResponseController(rw).Flush(); err != nil {
+			t.Errorf("Expected the compressing writer to support flushing, got %v\n", err)
+		}
+		flushedBytes = w.Body.Len()
+		rw.Write([]byte(`{"id": 2}` + "\n"))
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	// Act
+	handler.ServeHTTP(w, r)
+
+	// Assert
+	if !w.Flushed || flushedBytes == 0 {
+		t.Errorf("Expected the first chunk to reach the client on Flush, got %d bytes\n", flushedBytes)
+	}
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "gzip" {
+		t.Errorf("Expected flushed streams to be compressed below the minimum size, got %q\n", encoding)
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Failed to read gzip body: %v", err)
+	}
+	decoded, err := io.ReadAll(gz)
+	if err != nil || string(decoded) != "{\"id\": 1}\n{\"id\": 2}\n" {
+		t.Errorf("Expected both chunks in a complete gzip stream, got %q (%v)\n", decoded, err)
+	}
+}
@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/middleware"
+	"github.com/anishsharma21/go-web-dev-template/internal/respond"
+)
+
+func TestNotModifiedAnswersCurrentCopiesWith304(t *testing.T) {
+	lastModified := time.Date(2026, 10, 19, 15, 3, 0, 500, time.UTC)
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		wantStatus int
+	}{
+		{"matching etag", "If-None-Match", `"other", W/"42-abc"`, http.StatusNotModified},
+		{"changed etag", "If-None-Match", `"42-def"`, http.StatusOK},
+		{"compressed etag", "If-None-Match", `"42-abc-gzip"`, http.StatusNotModified},
+		{"unchanged since", "If-Modified-Since", lastModified.Format(http.TimeFormat), http.StatusNotModified},
+		{"changed since", "If-Modified-Since", lastModified.Add(-time.Minute).Format(http.TimeFormat), http.StatusOK},
+		{"no validators", "", "", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		// Arrange
+		r := httptest.NewRequest(http.MethodGet, "/v1/users/user_42", nil)
+		if test.header != "" {
+			r.Header.Set(test.header, test.value)
+		}
+		w := httptest.NewRecorder()
+
+		// Act
+		if !respond.NotModified(w, r, `"42-abc"`, lastModified) {
+			w.WriteHeader(http.StatusOK)
+		}
+
+		// Assert
+		if w.Code != test.wantStatus {
+			t.Errorf("%s: Expected status %d, got %d\n", test.name, test.wantStatus, w.Code)
+		}
+		wantETag := `"42-abc"`
+		if test.name == "compressed etag" {
+			// The 304 identifies the compressed representation the client has
+			wantETag = `"42-abc-gzip"`
+		}
+		if got := w.Header().Get("ETag"); got != wantETag {
+			t.Errorf("%s: Expected ETag header %q, got %q\n", test.name, wantETag, got)
+		}
+		if got := w.Header().Get("Last-Modified"); got != lastModified.Format(http.TimeFormat) {
+			t.Errorf("%s: Expected Last-Modified header %q, got %q\n", test.name, lastModified.Format(http.TimeFormat), got)
+		}
+	}
+}
+
+func TestCheckPreconditionsRejectsChangedResources(t *testing.T) {
+	lastModified := time.Date(2026, 10, 19, 15, 3, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		wantStatus int
+	}{
+		{"matching etag", "If-Match", `"42-abc"`, 0},
+		{"any etag", "If-Match", "*", 0},
+		{"changed etag", "If-Match", `"42-def"`, http.StatusPreconditionFailed},
+		{"weak etag", "If-Match", `W/"42-abc"`, http.StatusPreconditionFailed},
+		{"compressed etag", "If-Match", `"42-abc-br"`, 0},
+		{"changed compressed etag", "If-Match", `"42-def-br"`, http.StatusPreconditionFailed},
+		{"unmodified since", "If-Unmodified-Since", lastModified.Format(http.TimeFormat), 0},
+		{"modified since", "If-Unmodified-Since", lastModified.Add(-time.Minute).Format(http.TimeFormat), http.StatusPreconditionFailed},
+		{"no preconditions", "", "", 0},
+	}
+
+	for _, test := range tests {
+		// Arrange
+		r := httptest.NewRequest(http.MethodDelete, "/v1/users/42", nil)
+		if test.header != "" {
+			r.Header.Set(test.header, test.value)
+		}
+
+		// Act
+		err := respond.CheckPreconditions(r, `"42-abc"`, lastModified)
+
+		// Assert
+		var problem *respond.Problem
+		switch {
+		case test.wantStatus == 0 && err != nil:
+			t.Errorf("%s: Expected no error, got %v\n", test.name, err)
+		case test.wantStatus != 0 && (!errors.As(err, &problem) || problem.Status != test.wantStatus):
+			t.Errorf("%s: Expected a %d problem, got %v\n", test.name, test.wantStatus, err)
+		}
+	}
+}
+
+func TestCompressedResponsesHaveTheirOwnStrongETag(t *testing.T) {
+	// Arrange
+	handler := middleware.CompressionMiddleware(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if respond.NotModified(w, r, `"42-abc"`, time.Time{}) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":42}`))
+	}))
+	send := func(acceptEncoding string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/v1/me", nil)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// Act
+	identity := send("identity")
+	gzipped := send("gzip")
+
+	// Assert
+	if got := identity.Header().Get("ETag"); got != `"42-abc"` {
+		t.Errorf("Expected the uncompressed response to keep its ETag, got %q\n", got)
+	}
+	if got := gzipped.Header().Get("ETag"); got != `"42-abc-gzip"` || gzipped.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("Expected a strong ETag naming the gzip encoding, got %q\n", got)
+	}
+}
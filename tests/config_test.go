@@ -0,0 +1,206 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/config"
+	"github.com/anishsharma21/go-web-dev-template/internal/secrets"
+)
+
+func TestConfigPrecedence(t *testing.T) {
+	// Arrange
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := "environment: production\nport: \"9000\"\nhttp:\n  write_timeout: 45s\n  idle_timeout: 90s\n"
+	if err := os.WriteFile(configFile, []byte(yaml), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	t.Setenv("ENVIRONMENT", "")
+	t.Setenv("CONFIG_FILE", configFile)
+	t.Setenv("DATABASE_URL", "postgres://localhost:5432/db")
+	t.Setenv("CLERK_SECRET_KEY", "sk_test")
+	t.Setenv("CLERK_WEBHOOK_SIGNING_SECRET", "whsec_test")
+	t.Setenv("HTTP_IDLE_TIMEOUT", "2m")
+
+	// Act
+	cfg, err := config.Load([]string{"-port", "9100"})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error loading config, got %v\n", err)
+	}
+	if cfg.Environment != config.Production {
+		t.Errorf("Expected environment from config file, got %q\n", cfg.Environment)
+	}
+	if cfg.Security.HSTSMaxAge == 0 {
+		t.Errorf("Expected production defaults to enable HSTS\n")
+	}
+	if cfg.HTTP.WriteTimeout != 45*time.Second {
+		t.Errorf("Expected write timeout from config file, got %v\n", cfg.HTTP.WriteTimeout)
+	}
+	if cfg.HTTP.IdleTimeout != 2*time.Minute {
+		t.Errorf("Expected idle timeout from environment to override config file, got %v\n", cfg.HTTP.IdleTimeout)
+	}
+	if cfg.Port != "9100" {
+		t.Errorf("Expected port from flag to override config file, got %q\n", cfg.Port)
+	}
+}
+
+func TestConfigReadsTOMLFiles(t *testing.T) {
+	// Arrange
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	toml := "environment = \"production\"\nport = \"9000\"\n\n[http]\nwrite_timeout = \"45s\"\n\n[cors]\nallowed_origins = [\"https://app.example.com\"]\n"
+	if err := os.WriteFile(configFile, []byte(toml), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	t.Setenv("ENVIRONMENT", "")
+	t.Setenv("CONFIG_FILE", configFile)
+	t.Setenv("DATABASE_URL", "postgres://localhost:5432/db")
+	t.Setenv("CLERK_SECRET_KEY", "sk_test")
+	t.Setenv("CLERK_WEBHOOK_SIGNING_SECRET", "whsec_test")
+
+	// Act
+	cfg, err := config.Load(nil)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error loading config, got %v\n", err)
+	}
+	if cfg.Environment != config.Production || cfg.Port != "9000" {
+		t.Errorf("Expected environment and port from the TOML file, got %q and %q\n", cfg.Environment, cfg.Port)
+	}
+	if cfg.HTTP.WriteTimeout != 45*time.Second {
+		t.Errorf("Expected write timeout from the TOML file, got %v\n", cfg.HTTP.WriteTimeout)
+	}
+	if len(cfg.CORS.AllowedOrigins) != 1 || cfg.CORS.AllowedOrigins[0] != "https://app.example.com" {
+		t.Errorf("Expected allowed origins from the TOML file, got %v\n", cfg.CORS.AllowedOrigins)
+	}
+}
+
+func TestConfigRejectsUnsupportedFiles(t *testing.T) {
+	// Arrange
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, []byte(`{"port": "9000"}`), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	t.Setenv("CONFIG_FILE", configFile)
+
+	// Act
+	_, err := config.Load(nil)
+
+	// Assert
+	if err == nil || !strings.Contains(err.Error(), "Unsupported config file") {
+		t.Errorf("Expected an unsupported config file error, got %v\n", err)
+	}
+}
+
+func TestConfigValidationAggregatesErrors(t *testing.T) {
+	// Arrange
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("ENVIRONMENT", "production")
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("CLERK_SECRET_KEY", "")
+	t.Setenv("CLERK_WEBHOOK_SIGNING_SECRET", "")
+	t.Setenv("TRUSTED_PROXIES", "not-an-ip")
+
+	// Act
+	_, err := config.Load(nil)
+
+	// Assert
+	if !errors.Is(err, config.ErrInvalid) {
+		t.Fatalf("Expected invalid configuration error, got %v\n", err)
+	}
+	for _, key := range []string{"DATABASE_URL", "CLERK_SECRET_KEY", "CLERK_WEBHOOK_SIGNING_SECRET", "TRUSTED_PROXIES"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("Expected error to mention %s, got %v\n", key, err)
+		}
+	}
+}
+
+func TestConfigSecretsFromFiles(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	writeSecret := func(name, value string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(value+"\n"), 0o600); err != nil {
+			t.Fatalf("Failed to write secret file: %v", err)
+		}
+	}
+	writeSecret("clerk_key", "sk_from_file")
+	writeSecret("clerk_webhook_signing_secret", "whsec_old")
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("ENVIRONMENT", "test")
+	t.Setenv("DATABASE_URL", "postgres://localhost:5432/db")
+	t.Setenv("CLERK_SECRET_KEY", "") // restored after the test
+	os.Unsetenv("CLERK_SECRET_KEY")
+	t.Setenv("CLERK_SECRET_KEY_FILE", filepath.Join(dir, "clerk_key"))
+	t.Setenv("CLERK_WEBHOOK_SIGNING_SECRET", "") // restored after the test
+	os.Unsetenv("CLERK_WEBHOOK_SIGNING_SECRET")
+	t.Setenv("SECRETS_DIR", dir)
+
+	// Act
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Expected no error loading config, got %v\n", err)
+	}
+	webhookSecret := cfg.SecretValue("CLERK_WEBHOOK_SIGNING_SECRET")
+	writeSecret("clerk_webhook_signing_secret", "whsec_new")
+	changed, err := webhookSecret.Refresh(context.Background())
+
+	// Assert
+	if cfg.Clerk.SecretKey != "sk_from_file" {
+		t.Errorf("Expected secret key from CLERK_SECRET_KEY_FILE, got %q\n", cfg.Clerk.SecretKey)
+	}
+	if cfg.Clerk.WebhookSigningSecret != "whsec_old" {
+		t.Errorf("Expected webhook secret from SECRETS_DIR, got %q\n", cfg.Clerk.WebhookSigningSecret)
+	}
+	if err != nil || !changed || webhookSecret.Get() != "whsec_new" {
+		t.Errorf("Expected rotated webhook secret to be reloaded, got %q (changed=%v, err=%v)\n", webhookSecret.Get(), changed, err)
+	}
+}
+
+// mapSecretsProvider serves secrets from a map, standing in for an external secret store
+type mapSecretsProvider map[string]string
+
+func (p mapSecretsProvider) Secret(_ context.Context, name string) (string, error) {
+	value, ok := p[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", secrets.ErrNotFound, name)
+	}
+	return value, nil
+}
+
+func TestConfigSecretsFromProvider(t *testing.T) {
+	// Arrange
+	provider := mapSecretsProvider{"clerk_secret_key": "sk_from_store", "clerk_webhook_signing_secret": "whsec_old"}
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("ENVIRONMENT", "test")
+	t.Setenv("SECRETS_DIR", "")
+	t.Setenv("DATABASE_URL", "postgres://localhost:5432/db")
+	t.Setenv("CLERK_SECRET_KEY", "") // restored after the test
+	os.Unsetenv("CLERK_SECRET_KEY")
+	t.Setenv("CLERK_WEBHOOK_SIGNING_SECRET", "") // restored after the test
+	os.Unsetenv("CLERK_WEBHOOK_SIGNING_SECRET")
+
+	// Act
+	cfg, err := config.Load(nil, config.WithSecretsProvider(provider))
+	if err != nil {
+		t.Fatalf("Expected no error loading config, got %v\n", err)
+	}
+	webhookSecret := cfg.SecretValue("CLERK_WEBHOOK_SIGNING_SECRET")
+	provider["clerk_webhook_signing_secret"] = "whsec_new"
+	changed, err := webhookSecret.Refresh(context.Background())
+
+	// Assert
+	if cfg.Clerk.SecretKey != "sk_from_store" {
+		t.Errorf("Expected secret key from the provider, got %q\n", cfg.Clerk.SecretKey)
+	}
+	if err != nil || !changed || webhookSecret.Get() != "whsec_new" {
+		t.Errorf("Expected rotated webhook secret to be reloaded from the provider, got %q (changed=%v, err=%v)\n", webhookSecret.Get(), changed, err)
+	}
+}
@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/config"
+	"github.com/anishsharma21/go-web-dev-template/internal/middleware"
+)
+
+func newCORSHandler(opts middleware.CORSOptions, reached *bool) http.Handler {
+	return middleware.CORSMiddleware(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*reached = true
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestCORSAllowsListedOrigins(t *testing.T) {
+	opts := middleware.CORSOptions{
+		AllowedOrigins: []string{"https://app.example.com", "https://*.example.org"},
+		ExposedHeaders: []string{"X-Request-ID"},
+	}
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.com", true},
+		{"https://APP.example.com", true},
+		{"https://other.example.com", false},
+		{"https://app.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://evil-example.org", false},
+		{"http://app.example.org", false},
+		{"https://user@app.example.org", false},
+		{"https://app.example.org:8443", false},
+		{"https://example.org.evil.com", false},
+	}
+
+	for _, test := range tests {
+		// Arrange
+		var reached bool
+		handler := newCORSHandler(opts, &reached)
+		r := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+		r.Header.Set("Origin", test.origin)
+		w := httptest.NewRecorder()
+
+		// Act
+		handler.ServeHTTP(w, r)
+
+		// Assert
+		if !reached {
+			t.Errorf("Expected simple request from %s to reach the handler\n", test.origin)
+		}
+		allowOrigin := w.Header().Get("Access-Control-Allow-Origin")
+		if test.allowed && allowOrigin != test.origin {
+			t.Errorf("Expected %s to be allowed, got Access-Control-Allow-Origin %q\n", test.origin, allowOrigin)
+		}
+		if !test.allowed && allowOrigin != "" {
+			t.Errorf("Expected %s to be rejected, got Access-Control-Allow-Origin %q\n", test.origin, allowOrigin)
+		}
+		if exposed := w.Header().Get("Access-Control-Expose-Headers"); test.allowed != (exposed == "X-Request-ID") {
+			t.Errorf("Expected exposed headers only for allowed origins, got %q for %s\n", exposed, test.origin)
+		}
+		if !slices.Contains(w.Header().Values("Vary"), "Origin") {
+			t.Errorf("Expected Vary: Origin for %s, got %v\n", test.origin, w.Header().Values("Vary"))
+		}
+	}
+}
+
+func TestCORSAnswersPreflightWithoutReachingHandler(t *testing.T) {
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.com", true},
+		{"https://evil.com", false},
+	}
+
+	for _, test := range tests {
+		// Arrange
+		var reached bool
+		handler := newCORSHandler(middleware.CORSOptions{
+			AllowedOrigins: []string{"https://app.example.com"},
+			AllowedMethods: []string{"GET", "POST"},
+			AllowedHeaders: []string{"Authorization", "Content-Type"},
+			MaxAge:         10 * time.Minute,
+		}, &reached)
+		r := httptest.NewRequest(http.MethodOptions, "/v1/users", nil)
+		r.Header.Set("Origin", test.origin)
+		r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		w := httptest.NewRecorder()
+
+		// Act
+		handler.ServeHTTP(w, r)
+
+		// Assert
+		if reached {
+			t.Errorf("Expected preflight from %s not to reach the handler\n", test.origin)
+		}
+		if w.Code != http.StatusNoContent {
+			t.Errorf("Expected status %d for %s, got %d\n", http.StatusNoContent, test.origin, w.Code)
+		}
+		vary := w.Header().Values("Vary")
+		for _, want := range []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"} {
+			if !slices.Contains(vary, want) {
+				t.Errorf("Expected Vary to contain %s for %s, got %v\n", want, test.origin, vary)
+			}
+		}
+		header := w.Header()
+		if !test.allowed {
+			if header.Get("Access-Control-Allow-Origin") != "" || header.Get("Access-Control-Allow-Methods") != "" {
+				t.Errorf("Expected no CORS headers for %s, got %v\n", test.origin, header)
+			}
+			continue
+		}
+		if header.Get("Access-Control-Allow-Origin") != test.origin {
+			t.Errorf("Expected origin %s to be allowed, got %q\n", test.origin, header.Get("Access-Control-Allow-Origin"))
+		}
+		if header.Get("Access-Control-Allow-Methods") != "GET, POST" {
+			t.Errorf("Expected allowed methods, got %q\n", header.Get("Access-Control-Allow-Methods"))
+		}
+		if header.Get("Access-Control-Allow-Headers") != "Authorization, Content-Type" {
+			t.Errorf("Expected allowed headers, got %q\n", header.Get("Access-Control-Allow-Headers"))
+		}
+		if header.Get("Access-Control-Max-Age") != "600" {
+			t.Errorf("Expected max age of 600 seconds, got %q\n", header.Get("Access-Control-Max-Age"))
+		}
+	}
+}
+
+func TestCORSWildcardOrigin(t *testing.T) {
+	tests := []struct {
+		allowCredentials bool
+		wantAllowOrigin  string
+	}{
+		{false, "*"},
+		{true, ""},
+	}
+
+	for _, test := range tests {
+		// Arrange
+		var reached bool
+		handler := newCORSHandler(middleware.CORSOptions{
+			AllowedOrigins:   []string{"*"},
+			AllowCredentials: test.allowCredentials,
+		}, &reached)
+		r := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+		r.Header.Set("Origin", "https://evil.com")
+		w := httptest.NewRecorder()
+
+		// Act
+		handler.ServeHTTP(w, r)
+
+		// Assert
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != test.wantAllowOrigin {
+			t.Errorf("Expected Access-Control-Allow-Origin %q with credentials %t, got %q\n", test.wantAllowOrigin, test.allowCredentials, got)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+			t.Errorf("Expected no Access-Control-Allow-Credentials for an unlisted origin, got %q\n", got)
+		}
+	}
+}
+
+func TestCORSCredentialsOnlyForListedOrigins(t *testing.T) {
+	// Arrange
+	var reached bool
+	handler := newCORSHandler(middleware.CORSOptions{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowCredentials: true,
+	}, &reached)
+	r := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	w := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(w, r)
+
+	// Assert
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Expected the listed origin to be echoed, got %q\n", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Expected Access-Control-Allow-Credentials true, got %q\n", got)
+	}
+}
+
+func TestCORSRequestsWithoutOriginPassThrough(t *testing.T) {
+	// Arrange
+	var reached bool
+	handler := newCORSHandler(middleware.CORSOptions{AllowedOrigins: []string{"https://app.example.com"}}, &reached)
+	r := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+	w := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(w, r)
+
+	// Assert
+	if !reached {
+		t.Errorf("Expected request without Origin to reach the handler\n")
+	}
+	if len(w.Header().Values("Vary")) != 0 || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS headers without Origin, got %v\n", w.Header())
+	}
+}
+
+func TestConfigRejectsWildcardOriginWithCredentials(t *testing.T) {
+	// Arrange
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("ENVIRONMENT", "development")
+	t.Setenv("DATABASE_URL", "postgres://localhost:5432/db")
+	t.Setenv("CLERK_SECRET_KEY", "sk_test")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "*")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+
+	// Act
+	_, err := config.Load(nil)
+
+	// Assert
+	if !errors.Is(err, config.ErrInvalid) || !strings.Contains(err.Error(), "CORS_ALLOW_CREDENTIALS") {
+		t.Errorf("Expected wildcard origin with credentials to be rejected, got %v\n", err)
+	}
+}
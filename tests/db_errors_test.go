@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/apperr"
+	"github.com/anishsharma21/go-web-dev-template/internal/db"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestTranslateErrorMapsPostgresErrorsToDomainErrors(t *testing.T) {
+	connectionErr := errors.New("connection reset by peer")
+	tests := []struct {
+		err         error
+		wantKind    error
+		wantMessage string
+	}{
+		{fmt.Errorf("failed to get user: %w", pgx.ErrNoRows), apperr.ErrNotFound, "user not found"},
+		{&pgconn.PgError{Code: "23505"}, apperr.ErrConflict, "user already exists"},
+		{&pgconn.PgError{Code: "23503"}, apperr.ErrConflict, "user conflicts with a related record"},
+		{&pgconn.PgError{Code: "22P02"}, apperr.ErrInvalidInput, "invalid user input"},
+	}
+
+	for _, test := range tests {
+		// Act
+		err := db.TranslateError(test.err, "user")
+
+		// Assert
+		var appErr *apperr.Error
+		if !errors.As(err, &appErr) || !errors.Is(err, test.wantKind) {
+			t.Errorf("Expected %v to translate to %v, got %v\n", test.err, test.wantKind, err)
+			continue
+		}
+		if appErr.Message != test.wantMessage {
+			t.Errorf("Expected message %q, got %q\n", test.wantMessage, appErr.Message)
+		}
+		if !errors.Is(err, test.err) {
+			t.Errorf("Expected the original error to stay wrapped, got %v\n", err)
+		}
+	}
+
+	// Act
+	err := db.TranslateError(connectionErr, "user")
+
+	// Assert
+	if err != connectionErr {
+		t.Errorf("Expected other errors to be returned unchanged, got %v\n", err)
+	}
+}
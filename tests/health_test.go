@@ -0,0 +1,213 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/handlers"
+	"github.com/anishsharma21/go-web-dev-template/internal/health"
+	"github.com/anishsharma21/go-web-dev-template/internal/setup"
+	"github.com/clerk/clerk-sdk-go/v2"
+	"github.com/clerk/clerk-sdk-go/v2/jwks"
+)
+
+func readyz(checker *health.Checker) (int, health.Report, string) {
+	w := httptest.NewRecorder()
+	handlers.Readyz(checker).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	body := w.Body.String()
+	var report health.Report
+	json.Unmarshal([]byte(body), &report)
+	return w.Code, report, body
+}
+
+func TestReadinessFollowsStartupAndChecks(t *testing.T) {
+	// Arrange
+	secretErr := errors.New("failed to connect to `user=admin host=db.internal.example.com`")
+	var failing atomic.Bool
+	checker := health.NewChecker(
+		health.Check{Name: "database", Run: func(ctx context.Context) error {
+			if failing.Load() {
+				return secretErr
+			}
+			return nil
+		}},
+		health.Check{Name: "clerk_jwks", Run: func(ctx context.Context) error { return nil }},
+	)
+
+	// Act
+	startingCode, starting, _ := readyz(checker)
+	checker.MarkStarted()
+	readyCode, ready, _ := readyz(checker)
+	failing.Store(true)
+	failedCode, failed, failedBody := readyz(checker)
+
+	// Assert
+	if startingCode != http.StatusServiceUnavailable || starting.Status != health.StatusStarting {
+		t.Errorf("Expected 503 starting before MarkStarted, got %d %q\n", startingCode, starting.Status)
+	}
+	if readyCode != http.StatusOK || ready.Status != health.StatusOK || len(ready.Checks) != 2 {
+		t.Errorf("Expected 200 ok with both checks, got %d %+v\n", readyCode, ready)
+	}
+	if failedCode != http.StatusServiceUnavailable || failed.Status != health.StatusFail {
+		t.Errorf("Expected 503 fail when a check fails, got %d %q\n", failedCode, failed.Status)
+	}
+	if failed.Checks["database"].Status != health.StatusFail || failed.Checks["clerk_jwks"].Status != health.StatusOK {
+		t.Errorf("Expected only the database check to fail, got %+v\n", failed.Checks)
+	}
+	if strings.Contains(failedBody, "db.internal.example.com") || strings.Contains(failedBody, "admin") {
+		t.Errorf("Expected check errors not to be returned, got %s\n", failedBody)
+	}
+}
+
+func TestReadinessBoundsEachCheckByItsTimeout(t *testing.T) {
+	// Arrange
+	checker := health.NewChecker(
+		health.Check{Name: "slow", Timeout: 20 * time.Millisecond, Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+		health.Check{Name: "fast", Timeout: 20 * time.Millisecond, Run: func(ctx context.Context) error { return nil }},
+	)
+	checker.MarkStarted()
+	start := time.Now()
+
+	// Act
+	report := checker.Readiness(context.Background())
+
+	// Assert
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the slow check to be cancelled after its timeout, took %s\n", elapsed)
+	}
+	if report.Status != health.StatusFail || report.Checks["slow"].Status != health.StatusFail || report.Checks["fast"].Status != health.StatusOK {
+		t.Errorf("Expected only the timed out check to fail, got %+v\n", report)
+	}
+}
+
+func TestDrainFailsReadinessForTheShutdownDelay(t *testing.T) {
+	// Arrange
+	checker := health.NewChecker()
+	checker.MarkStarted()
+	start := time.Now()
+
+	// Act
+	err := checker.Drain(context.Background(), 50*time.Millisecond)
+	code, report, _ := readyz(checker)
+
+	// Assert
+	if err != nil {
+		t.Errorf("Expected drain to complete, got %v\n", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected drain to wait for the shutdown delay, took %s\n", elapsed)
+	}
+	if code != http.StatusServiceUnavailable || report.Status != health.StatusShuttingDown {
+		t.Errorf("Expected 503 shutting_down after drain, got %d %q\n", code, report.Status)
+	}
+}
+
+func TestDrainStopsWaitingWhenTheShutdownTimesOut(t *testing.T) {
+	// Arrange
+	checker := health.NewChecker()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := checker.Drain(ctx, time.Minute)
+
+	// Assert
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the shutdown deadline to end the drain, got %v\n", err)
+	}
+	if report := checker.Readiness(context.Background()); report.Status != health.StatusShuttingDown {
+		t.Errorf("Expected readiness to fail as soon as drain starts, got %q\n", report.Status)
+	}
+}
+
+// jwksServer serves a key set, blocking requests while block is open
+func jwksServer(t *testing.T, requests *atomic.Int32, block chan struct{}) *jwks.Client {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	keySet := fmt.Sprintf(`{"keys": [{"kty": "RSA", "kid": "test", "use": "sig", "alg": "RS256", "n": %q, "e": %q}]}`,
+		base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 && block != nil {
+			select {
+			case <-block:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(keySet))
+	}))
+	t.Cleanup(ts.Close)
+
+	return jwks.NewClient(&clerk.ClientConfig{
+		BackendConfig: clerk.BackendConfig{Key: clerk.String("sk_test"), URL: clerk.String(ts.URL)},
+	})
+}
+
+func TestJWKSCheckCachesSuccessfulFetches(t *testing.T) {
+	// Arrange
+	var requests atomic.Int32
+	check := setup.JWKSCheck(jwksServer(t, &requests, nil), time.Hour)
+
+	// Act
+	first := check(context.Background())
+	second := check(context.Background())
+
+	// Assert
+	if first != nil || second != nil {
+		t.Fatalf("Expected both checks to pass, got %v and %v\n", first, second)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("Expected the second check to use the cached result, got %d requests\n", requests.Load())
+	}
+}
+
+func TestJWKSCheckDoesNotQueueBehindSlowFetches(t *testing.T) {
+	// Arrange
+	var requests atomic.Int32
+	block := make(chan struct{})
+	defer close(block)
+	check := setup.JWKSCheck(jwksServer(t, &requests, block), time.Hour)
+	// The slow fetch gives up eventually, so a check queued behind it fails instead of hanging
+	slowCtx, cancelSlow := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelSlow()
+	go check(slowCtx)
+	for requests.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+
+	// Act
+	err := check(ctx)
+
+	// Assert
+	if err != nil {
+		t.Errorf("Expected the concurrent check to fetch the keys itself, got %v\n", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the concurrent check not to wait for the slow fetch, took %s\n", elapsed)
+	}
+}
@@ -0,0 +1,276 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/client"
+	"github.com/anishsharma21/go-web-dev-template/internal/db"
+	"github.com/anishsharma21/go-web-dev-template/internal/middleware"
+	"github.com/anishsharma21/go-web-dev-template/internal/respond"
+	"github.com/anishsharma21/go-web-dev-template/internal/types/models"
+)
+
+// memoryIdempotencyStore keeps idempotency keys in memory, without expiry
+type memoryIdempotencyStore struct {
+	mu   sync.Mutex
+	keys map[string]models.IdempotencyKey
+}
+
+func (s *memoryIdempotencyStore) Claim(ctx context.Context, scope, key, fingerprint string) (models.IdempotencyKey, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record, ok := s.keys[scope+"/"+key]; ok {
+		return record, false, nil
+	}
+	record := models.IdempotencyKey{Scope: scope, Key: key, Fingerprint: fingerprint}
+	s.keys[scope+"/"+key] = record
+	return record, true, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(ctx context.Context, claim models.IdempotencyKey, statusCode int, header http.Header, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := s.keys[claim.Scope+"/"+claim.Key]
+	record.StatusCode, record.Header, record.Body = &statusCode, header, body
+	s.keys[claim.Scope+"/"+claim.Key] = record
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(ctx context.Context, claim models.IdempotencyKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, claim.Scope+"/"+claim.Key)
+	return nil
+}
+
+func TestIdempotencyMiddlewareReplaysAndRejectsReusedKeys(t *testing.T) {
+	// Arrange
+	store := &memoryIdempotencyStore{keys: make(map[string]models.IdempotencyKey)}
+	var calls atomic.Int32
+	var handler http.Handler
+	var inFlight *httptest.ResponseRecorder
+	send := func(key, target, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		r.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	handler = middleware.IdempotencyMiddleware(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RawQuery == "repeat" {
+			// Repeated while the first request is still running
+			inFlight = send(r.Header.Get("Idempotency-Key"), r.URL.RequestURI(), `{}`)
+		}
+		if calls.Add(1) == 1 && r.URL.RawQuery == "fail" {
+			respond.Problemf(w, r, http.StatusServiceUnavailable, "Request timed out")
+			return
+		}
+		w.Header().Set("Location", "/v1/users/user_42")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"clerk_id":"user_42"}`))
+	}))
+
+	// Act
+	first := send("key-1", "/v1/signup", `{"clerk_id":"user_42"}`)
+	repeat := send("key-1", "/v1/signup", `{"clerk_id":"user_42"}`)
+	reused := send("key-1", "/v1/signup", `{"clerk_id":"user_43"}`)
+
+	// Assert
+	if first.Code != http.StatusCreated || repeat.Code != http.StatusCreated {
+		t.Fatalf("Expected both responses to be 201, got %d and %d\n", first.Code, repeat.Code)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected the handler to run once, got %d calls\n", calls.Load())
+	}
+	if repeat.Header().Get("Idempotent-Replayed") != "true" || repeat.Header().Get("Location") != "/v1/users/user_42" {
+		t.Errorf("Expected the replayed response to keep its headers, got %v\n", repeat.Header())
+	}
+	if repeat.Body.String() != first.Body.String() {
+		t.Errorf("Expected the replayed body %q, got %q\n", first.Body.String(), repeat.Body.String())
+	}
+	if reused.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d for a key reused with a different body, got %d\n", http.StatusUnprocessableEntity, reused.Code)
+	}
+
+	// Act
+	send("key-2", "/v1/signup?repeat", `{}`)
+	calls.Store(0)
+	failed := send("key-3", "/v1/signup?fail", `{}`)
+	retried := send("key-3", "/v1/signup?fail", `{}`)
+
+	// Assert
+	var problem respond.Problem
+	json.Unmarshal(inFlight.Body.Bytes(), &problem)
+	if inFlight.Code != http.StatusConflict || problem.Type != middleware.IdempotencyKeyInUseProblem {
+		t.Errorf("Expected a %q problem for a request still running, got %d %q\n", middleware.IdempotencyKeyInUseProblem, inFlight.Code, problem.Type)
+	}
+	if failed.Code != http.StatusServiceUnavailable || retried.Code != http.StatusCreated {
+		t.Errorf("Expected a server error to be retried rather than replayed, got %d then %d\n", failed.Code, retried.Code)
+	}
+}
+
+func TestClientRetriesSignUpWithTheSameIdempotencyKey(t *testing.T) {
+	// Arrange
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(keys) == 1 {
+			respond.Problemf(w, r, http.StatusBadGateway, "Bad gateway")
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	c, err := client.New(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	// Act
+	err = c.SignUp(context.Background(), client.SignUpRequest{ClerkID: "user_42"})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected the retried sign up to succeed, got %v\n", err)
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("Expected two attempts with the same Idempotency-Key, got %q\n", keys)
+	}
+}
+
+func TestIdempotencyKeysReplayStoredResponses(t *testing.T) {
+	// Arrange
+	keys := db.NewIdempotencyKeys(dbPool, time.Hour)
+	defer dbPool.Exec(ctx, "DELETE FROM idempotency_keys WHERE key = $1", "test-replay")
+	header := http.Header{"Location": {"/v1/users/user_42"}, "Content-Type": {"application/json"}}
+
+	// Act
+	claim, claimed, err := keys.Claim(ctx, "", "test-replay", "fingerprint")
+	if err != nil || !claimed {
+		t.Fatalf("Expected the first request to claim the key, got claimed=%v, err=%v\n", claimed, err)
+	}
+	inFlight, claimedAgain, err := keys.Claim(ctx, "", "test-replay", "fingerprint")
+	if err != nil {
+		t.Fatalf("Expected no error claiming a claimed key, got %v\n", err)
+	}
+	if err := keys.Complete(ctx, claim, http.StatusCreated, header, []byte(`{"id":42}`)); err != nil {
+		t.Fatalf("Expected no error completing the key, got %v\n", err)
+	}
+	stored, _, err := keys.Claim(ctx, "", "test-replay", "fingerprint")
+
+	// Assert
+	if claimedAgain || inFlight.Completed() {
+		t.Errorf("Expected a repeat while running to see an incomplete claim, got claimed=%v, %+v\n", claimedAgain, inFlight)
+	}
+	if err != nil || !stored.Completed() || *stored.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected the stored 201 to be returned, got %+v, err=%v\n", stored, err)
+	}
+	if !reflect.DeepEqual(stored.Header, header) {
+		t.Errorf("Expected headers %v to round-trip, got %v\n", header, stored.Header)
+	}
+	if string(stored.Body) != `{"id":42}` {
+		t.Errorf("Expected the stored body, got %q\n", stored.Body)
+	}
+}
+
+func TestIdempotencyKeysAreClaimedAgainAfterExpiry(t *testing.T) {
+	// Arrange
+	keys := db.NewIdempotencyKeys(dbPool, time.Millisecond)
+	defer dbPool.Exec(ctx, "DELETE FROM idempotency_keys WHERE key = $1", "test-expiry")
+	claim, _, err := keys.Claim(ctx, "", "test-expiry", "first")
+	if err != nil {
+		t.Fatalf("Failed to claim key: %v", err)
+	}
+	if err := keys.Complete(ctx, claim, http.StatusCreated, nil, nil); err != nil {
+		t.Fatalf("Failed to complete key: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	// Act
+	record, claimed, err := keys.Claim(ctx, "", "test-expiry", "second")
+
+	// Assert
+	if err != nil || !claimed {
+		t.Fatalf("Expected the expired key to be claimed again, got claimed=%v, err=%v\n", claimed, err)
+	}
+	if record.Fingerprint != "second" || record.Completed() {
+		t.Errorf("Expected a fresh claim for the new request, got %+v\n", record)
+	}
+
+	// Act
+	deleted, err := keys.DeleteExpired(ctx)
+
+	// Assert
+	if err != nil || deleted < 1 {
+		t.Errorf("Expected the expired key to be deleted, got %d, err=%v\n", deleted, err)
+	}
+}
+
+func TestIdempotencyKeysTakeOverAbandonedClaims(t *testing.T) {
+	// Arrange
+	keys := db.NewIdempotencyKeys(dbPool, time.Hour)
+	defer dbPool.Exec(ctx, "DELETE FROM idempotency_keys WHERE key = $1", "test-abandoned")
+	if _, _, err := keys.Claim(ctx, "", "test-abandoned", "fingerprint"); err != nil {
+		t.Fatalf("Failed to claim key: %v", err)
+	}
+	// The instance running the first request crashed before completing it
+	_, err := dbPool.Exec(ctx, "UPDATE idempotency_keys SET created_at = created_at - INTERVAL '2 minutes' WHERE key = $1", "test-abandoned")
+	if err != nil {
+		t.Fatalf("Failed to backdate claim: %v", err)
+	}
+
+	// Act
+	_, claimed, err := keys.Claim(ctx, "", "test-abandoned", "fingerprint")
+
+	// Assert
+	if err != nil || !claimed {
+		t.Errorf("Expected the abandoned claim to be taken over, got claimed=%v, err=%v\n", claimed, err)
+	}
+}
+
+func TestIdempotencyKeysCanOnlyBeCompletedByTheirClaim(t *testing.T) {
+	// Arrange
+	keys := db.NewIdempotencyKeys(dbPool, time.Hour)
+	defer dbPool.Exec(ctx, "DELETE FROM idempotency_keys WHERE key = $1", "test-stale")
+	stale, _, err := keys.Claim(ctx, "", "test-stale", "fingerprint")
+	if err != nil {
+		t.Fatalf("Failed to claim key: %v", err)
+	}
+	// The first request ran past the lock timeout and a retry took its claim over
+	_, err = dbPool.Exec(ctx, "UPDATE idempotency_keys SET created_at = created_at - INTERVAL '2 minutes' WHERE key = $1", "test-stale")
+	if err != nil {
+		t.Fatalf("Failed to backdate claim: %v", err)
+	}
+	stale.CreatedAt = stale.CreatedAt.Add(-2 * time.Minute)
+	current, claimed, err := keys.Claim(ctx, "", "test-stale", "fingerprint")
+	if err != nil || !claimed {
+		t.Fatalf("Expected the retry to take over the claim, got claimed=%v, err=%v\n", claimed, err)
+	}
+
+	// Act
+	completeErr := keys.Complete(ctx, stale, http.StatusCreated, nil, []byte(`{"id":1}`))
+	releaseErr := keys.Release(ctx, stale)
+	inFlight, _, _ := keys.Claim(ctx, "", "test-stale", "fingerprint")
+
+	// Assert
+	if !errors.Is(completeErr, db.ErrIdempotencyClaimLost) || !errors.Is(releaseErr, db.ErrIdempotencyClaimLost) {
+		t.Errorf("Expected the stale claim not to complete or release the key, got %v and %v\n", completeErr, releaseErr)
+	}
+	if inFlight.Completed() || !inFlight.CreatedAt.Equal(current.CreatedAt) {
+		t.Errorf("Expected the retry's claim to be left running, got %+v\n", inFlight)
+	}
+	if err := keys.Complete(ctx, current, http.StatusCreated, nil, []byte(`{"id":2}`)); err != nil {
+		t.Errorf("Expected the retry to complete its claim, got %v\n", err)
+	}
+}
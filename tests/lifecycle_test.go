@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/lifecycle"
+)
+
+func TestLifecycleStartsInDependencyOrderAndStopsInReverse(t *testing.T) {
+	// Arrange
+	var events []string
+	hook := func(name string, dependsOn ...string) lifecycle.Hook {
+		return lifecycle.Hook{
+			Name:      name,
+			DependsOn: dependsOn,
+			Start: func(ctx context.Context) error {
+				events = append(events, "start "+name)
+				return nil
+			},
+			Stop: func(ctx context.Context) error {
+				events = append(events, "stop "+name)
+				return nil
+			},
+		}
+	}
+	app := lifecycle.New(time.Second)
+	app.Append(hook("migrations", "database", "http"))
+	app.Append(hook("http", "database"))
+	app.Append(hook("database"))
+	app.Append(lifecycle.Hook{
+		Name:      "worker",
+		DependsOn: []string{"migrations"},
+		Start: func(ctx context.Context) error {
+			events = append(events, "start worker")
+			app.Shutdown(nil)
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			<-ctx.Done() // never stops in time
+			return ctx.Err()
+		},
+		StopTimeout: 10 * time.Millisecond,
+	})
+
+	// Act
+	err := app.Run(context.Background())
+
+	// Assert
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the worker's stop timeout to be reported, got %v\n", err)
+	}
+	expected := []string{
+		"start database", "start http", "start migrations", "start worker",
+		"stop migrations", "stop http", "stop database",
+	}
+	if !slices.Equal(events, expected) {
+		t.Errorf("Expected events %v, got %v\n", expected, events)
+	}
+}
+
+func TestLifecycleStopsStartedHooksWhenStartFails(t *testing.T) {
+	// Arrange
+	var stopped []string
+	startErr := errors.New("port already in use")
+	app := lifecycle.New(time.Second)
+	app.Append(lifecycle.Hook{
+		Name: "database",
+		Stop: func(ctx context.Context) error {
+			stopped = append(stopped, "database")
+			return nil
+		},
+	})
+	app.Append(lifecycle.Hook{
+		Name:      "http",
+		DependsOn: []string{"database"},
+		Start: func(ctx context.Context) error {
+			return startErr
+		},
+		Stop: func(ctx context.Context) error {
+			stopped = append(stopped, "http")
+			return nil
+		},
+	})
+
+	// Act
+	err := app.Run(context.Background())
+
+	// Assert
+	if !errors.Is(err, startErr) {
+		t.Errorf("Expected start error to be returned, got %v\n", err)
+	}
+	if !slices.Equal(stopped, []string{"database"}) {
+		t.Errorf("Expected only the started database hook to be stopped, got %v\n", stopped)
+	}
+}
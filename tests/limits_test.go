@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/middleware"
+	"github.com/anishsharma21/go-web-dev-template/internal/respond"
+	"github.com/anishsharma21/go-web-dev-template/internal/validate"
+)
+
+func TestMaxBodyBytesRejectsLargeBodies(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		contentLength int64
+		wantStatus    int
+		wantReached   bool
+	}{
+		{"within the limit", `{"name": "a"}`, 13, http.StatusNoContent, true},
+		{"declared too large", strings.Repeat("a", 17), 17, http.StatusRequestEntityTooLarge, false},
+		{"too large without Content-Length", `{"name": "` + strings.Repeat("a", 32) + `"}`, -1, http.StatusRequestEntityTooLarge, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			var reached bool
+			handler := middleware.MaxBodyBytesMiddleware(16)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reached = true
+				var body struct {
+					Name string `json:"name"`
+				}
+				if err := validate.DecodeJSON(r, &body); err != nil {
+					respond.Error(w, r, err)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			r := httptest.NewRequest(http.MethodPost, "/v1/signup", strings.NewReader(test.body))
+			r.ContentLength = test.contentLength
+			w := httptest.NewRecorder()
+
+			// Act
+			handler.ServeHTTP(w, r)
+
+			// Assert
+			if w.Code != test.wantStatus {
+				t.Errorf("Expected status code %d, got %d\n", test.wantStatus, w.Code)
+			}
+			if reached != test.wantReached {
+				t.Errorf("Expected handler reached to be %t, got %t\n", test.wantReached, reached)
+			}
+			if test.wantStatus == http.StatusRequestEntityTooLarge {
+				var problem respond.Problem
+				if err := json.NewDecoder(w.Body).Decode(&problem); err != nil || problem.Detail != "Request body must not exceed 16 bytes" {
+					t.Errorf("Expected a problem naming the limit, got %+v (%v)\n", problem, err)
+				}
+			}
+		})
+	}
+}
+
+func TestTimeoutMiddlewareAnswersSlowHandlersWith504(t *testing.T) {
+	// Arrange
+	handlerErr := make(chan error, 1)
+	handler := middleware.TimeoutMiddleware(20 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.Header().Set("X-Late", "true")
+		_, err := w.Write([]byte("late"))
+		handlerErr <- err
+	}))
+	w := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/users", nil))
+
+	// Assert
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status code %d, got %d\n", http.StatusGatewayTimeout, w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+		t.Errorf("Expected a problem response, got content type %q\n", contentType)
+	}
+	if err := <-handlerErr; !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Errorf("Expected late writes to fail with ErrHandlerTimeout, got %v\n", err)
+	}
+	if w.Header().Get("X-Late") != "" || strings.Contains(w.Body.String(), "late") {
+		t.Errorf("Expected the late response to be discarded, got %v %q\n", w.Header(), w.Body.String())
+	}
+}
+
+// channelLogHandler sends the message of each log record to a channel
+type channelLogHandler struct {
+	messages chan string
+}
+
+func (h channelLogHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h channelLogHandler) Handle(_ context.Context, r slog.Record) error {
+	h.messages <- r.Message
+	return nil
+}
+func (h channelLogHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h channelLogHandler) WithGroup(string) slog.Handler      { return h }
+
+func TestTimeoutMiddlewareLogsPanicsAfterTheTimeout(t *testing.T) {
+	// Arrange
+	logs := channelLogHandler{messages: make(chan string, 10)}
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(logs))
+	defer slog.SetDefault(defaultLogger)
+	handler := middleware.TimeoutMiddleware(20 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		time.Sleep(10 * time.Millisecond)
+		panic("late failure")
+	}))
+	w := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/users", nil))
+
+	// Assert
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status code %d, got %d\n", http.StatusGatewayTimeout, w.Code)
+	}
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case message := <-logs.messages:
+			if message == "Handler panicked after the request timed out" {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("Expected the late panic to be logged\n")
+		}
+	}
+}
+
+func TestTimeoutMiddlewareSendsResponsesFinishedInTime(t *testing.T) {
+	// Arrange
+	handler := middleware.TimeoutMiddleware(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	}))
+	w := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/signup", nil))
+
+	// Assert
+	if w.Code != http.StatusCreated || w.Body.String() != "created" || w.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("Expected the buffered response, got %d %v %q\n", w.Code, w.Header(), w.Body.String())
+	}
+}
+
+func TestTimeoutMiddlewareDoesNotRespondToCancelledRequests(t *testing.T) {
+	// Arrange
+	handler := middleware.TimeoutMiddleware(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/users", nil).WithContext(ctx))
+
+	// Assert
+	if w.Body.Len() != 0 || w.Header().Get("Content-Type") != "" {
+		t.Errorf("Expected no response for a cancelled request, got %d %q\n", w.Code, w.Body.String())
+	}
+}
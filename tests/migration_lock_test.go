@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/setup"
+)
+
+func newTestMigrator(t *testing.T) *setup.Migrator {
+	t.Helper()
+	migrator, err := setup.NewMigrator(dbPool)
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+	t.Cleanup(func() { migrator.Close() })
+	return migrator
+}
+
+func TestMigrationLockSerialisesConcurrentCallers(t *testing.T) {
+	// Arrange
+	migrator := newTestMigrator(t)
+	var running, maxRunning, completed atomic.Int32
+	run := func(ctx context.Context) error {
+		current := running.Add(1)
+		defer running.Add(-1)
+		for {
+			seen := maxRunning.Load()
+			if current <= seen || maxRunning.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		completed.Add(1)
+		return nil
+	}
+
+	// Act
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = migrator.WithLock(ctx, 5*time.Second, 10*time.Millisecond, run)
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	for _, err := range errs {
+		if err != nil {
+			t.Errorf("Expected every caller to get the lock in turn, got %v\n", err)
+		}
+	}
+	if completed.Load() != 3 || maxRunning.Load() != 1 {
+		t.Errorf("Expected 3 callers to run one at a time, got %d completed with up to %d at once\n", completed.Load(), maxRunning.Load())
+	}
+}
+
+func TestMigrationLockTimesOutWhileHeldElsewhere(t *testing.T) {
+	// Arrange
+	migrator := newTestMigrator(t)
+	acquired, release := make(chan struct{}), make(chan struct{})
+	holderErr := make(chan error, 1)
+	go func() {
+		holderErr <- migrator.WithLock(ctx, 0, 10*time.Millisecond, func(ctx context.Context) error {
+			close(acquired)
+			<-release
+			return nil
+		})
+	}()
+	select {
+	case <-acquired:
+	case err := <-holderErr:
+		t.Fatalf("Failed to take the migration lock: %v", err)
+	}
+
+	// Act
+	timeoutErr := migrator.WithLock(ctx, 50*time.Millisecond, 10*time.Millisecond, func(ctx context.Context) error {
+		t.Errorf("Expected fn not to run without the lock\n")
+		return nil
+	})
+	cancelledCtx, cancelWait := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelWait()
+	cancelledErr := migrator.WithLock(cancelledCtx, 0, 10*time.Millisecond, func(ctx context.Context) error {
+		t.Errorf("Expected fn not to run without the lock\n")
+		return nil
+	})
+	close(release)
+
+	// Assert
+	if !errors.Is(timeoutErr, setup.ErrMigrationLockTimeout) {
+		t.Errorf("Expected ErrMigrationLockTimeout, got %v\n", timeoutErr)
+	}
+	if !errors.Is(cancelledErr, context.DeadlineExceeded) {
+		t.Errorf("Expected waiting to stop with the context, got %v\n", cancelledErr)
+	}
+	if err := <-holderErr; err != nil {
+		t.Errorf("Expected the holder to finish, got %v\n", err)
+	}
+}
+
+func TestMigrationLockIsReleasedWhenFnFails(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(cancel context.CancelFunc) error
+	}{
+		{"error", func(cancel context.CancelFunc) error { return errors.New("migration failed") }},
+		{"cancelled context", func(cancel context.CancelFunc) error {
+			cancel()
+			return context.Canceled
+		}},
+	}
+
+	for _, test := range tests {
+		// Arrange
+		migrator := newTestMigrator(t)
+		fnCtx, cancel := context.WithCancel(ctx)
+		if err := migrator.WithLock(fnCtx, time.Second, 10*time.Millisecond, func(ctx context.Context) error {
+			return test.fn(cancel)
+		}); err == nil {
+			t.Fatalf("%s: expected fn's error to be returned\n", test.name)
+		}
+		cancel()
+
+		// Act
+		err := migrator.WithLock(ctx, 100*time.Millisecond, 10*time.Millisecond, func(ctx context.Context) error { return nil })
+
+		// Assert
+		if err != nil {
+			t.Errorf("%s: expected the lock to be released, got %v\n", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/setup"
+)
+
+func TestParseMigrationCommand(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    setup.MigrationCommand
+		wantErr string
+	}{
+		{[]string{"up"}, setup.MigrationCommand{Name: "up"}, ""},
+		{[]string{"down"}, setup.MigrationCommand{Name: "down"}, ""},
+		{[]string{"status"}, setup.MigrationCommand{Name: "status"}, ""},
+		{[]string{"redo"}, setup.MigrationCommand{Name: "redo"}, ""},
+		{[]string{"to", "20250517022108"}, setup.MigrationCommand{Name: "to", Version: 20250517022108}, ""},
+		{[]string{"to", "0"}, setup.MigrationCommand{Name: "to"}, ""},
+		{[]string{"to"}, setup.MigrationCommand{}, "Usage: main migrate to <version>"},
+		{[]string{"to", "1", "2"}, setup.MigrationCommand{}, "Usage: main migrate to <version>"},
+		{[]string{"to", "-1"}, setup.MigrationCommand{}, "Invalid migration version"},
+		{[]string{"to", "latest"}, setup.MigrationCommand{}, "Invalid migration version"},
+		{[]string{"sideways"}, setup.MigrationCommand{}, "unknown migrate command"},
+		{nil, setup.MigrationCommand{}, "No migrate command given"},
+	}
+
+	for _, test := range tests {
+		// Act
+		command, err := setup.ParseMigrationCommand(test.args)
+
+		// Assert
+		if test.wantErr == "" && err != nil {
+			t.Errorf("Expected %v to parse, got %v\n", test.args, err)
+		}
+		if test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+			t.Errorf("Expected an error containing %q for %v, got %v\n", test.wantErr, test.args, err)
+		}
+		if command != test.want {
+			t.Errorf("Expected %+v for %v, got %+v\n", test.want, test.args, command)
+		}
+	}
+
+	// Assert
+	if _, err := setup.ParseMigrationCommand([]string{"sideways"}); !errors.Is(err, setup.ErrUnknownMigrationCommand) {
+		t.Errorf("Expected ErrUnknownMigrationCommand, got %v\n", err)
+	}
+}
+
+func TestCreateMigrationWritesSQLAndGoFiles(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+
+	// Act
+	sqlErr := setup.CreateMigration(dir, "add_widgets", "sql")
+	goErr := setup.CreateMigration(dir, "backfill_widgets", "go")
+	invalidErr := setup.CreateMigration(dir, "add_gadgets", "yaml")
+
+	// Assert
+	if sqlErr != nil || goErr != nil {
+		t.Fatalf("Expected sql and go migrations to be created, got %v and %v\n", sqlErr, goErr)
+	}
+	if invalidErr == nil {
+		t.Errorf("Expected an unsupported migration type to be rejected\n")
+	}
+	for _, pattern := range []string{"*_add_widgets.sql", "*_backfill_widgets.go"} {
+		matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+		if len(matches) != 1 {
+			t.Errorf("Expected one file matching %s, got %v\n", pattern, matches)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("Expected only the two valid migrations to be written, got %d files\n", len(entries))
+	}
+}
+
+func TestCheckVersionRejectsNewerSchemas(t *testing.T) {
+	// Arrange
+	migrator, err := setup.NewMigrator(dbPool)
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+	defer migrator.Close()
+	if err := migrator.CheckVersion(ctx); err != nil {
+		t.Fatalf("Expected the migrated test database to match this binary, got %v\n", err)
+	}
+	// A newer release applied a migration this binary doesn't know about
+	newerVersion := migrator.LatestVersion() + 1
+	if _, err := dbPool.Exec(ctx, "INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, true)", newerVersion); err != nil {
+		t.Fatalf("Failed to record a newer migration: %v", err)
+	}
+	defer dbPool.Exec(ctx, "DELETE FROM goose_db_version WHERE version_id = $1", newerVersion)
+
+	// Act
+	err = migrator.CheckVersion(ctx)
+
+	// Assert
+	if !errors.Is(err, setup.ErrSchemaTooNew) {
+		t.Errorf("Expected ErrSchemaTooNew, got %v\n", err)
+	}
+}
+
+func TestMigratorRunRejectsReadOnlyCommands(t *testing.T) {
+	// Arrange
+	migrator, err := setup.NewMigrator(dbPool)
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+	defer migrator.Close()
+
+	// Act
+	err = migrator.Run(ctx, setup.MigrationCommand{Name: "status"})
+
+	// Assert
+	if !errors.Is(err, setup.ErrUnknownMigrationCommand) {
+		t.Errorf("Expected status not to be run as a schema change, got %v\n", err)
+	}
+}
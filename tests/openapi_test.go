@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/config"
+	"github.com/anishsharma21/go-web-dev-template/internal/db"
+	"github.com/anishsharma21/go-web-dev-template/internal/health"
+	"github.com/anishsharma21/go-web-dev-template/internal/middleware"
+	"github.com/anishsharma21/go-web-dev-template/internal/secrets"
+	"github.com/anishsharma21/go-web-dev-template/internal/setup"
+)
+
+// testRoutes builds the application's routes without connecting to anything
+func testRoutes(t *testing.T) http.Handler {
+	t.Helper()
+	return testRoutesFor(t, config.Defaults(config.Development))
+}
+
+// testRoutesFor builds the application's routes for cfg without connecting to anything
+func testRoutesFor(t *testing.T, cfg config.Config) http.Handler {
+	t.Helper()
+	handler, err := setup.Routes(&cfg, setup.Dependencies{
+		DB:            db.NewRouter(dbPool, nil, 0),
+		JWKSClient:    middleware.NewJWKSClient("sk_test"),
+		WebhookSecret: secrets.NewValue("CLERK_WEBHOOK_SIGNING_SECRET", "", nil),
+		Health:        health.NewChecker(),
+	})
+	if err != nil {
+		// Routes fails when a route has neither documentation nor is marked internal
+		t.Fatalf("Expected routes to be created, got %v\n", err)
+	}
+	return handler
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	// Arrange
+	handler := testRoutes(t)
+	w := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	// Assert
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %v\n", w.Code)
+	}
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			RequestBody *struct {
+				Content map[string]struct {
+					Schema struct {
+						Ref string `json:"$ref"`
+					} `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+			Responses map[string]any        `json:"responses"`
+			Security  []map[string][]string `json:"security"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("Failed to decode OpenAPI document: %v", err)
+	}
+
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("Expected OpenAPI 3.1.0, got %q\n", doc.OpenAPI)
+	}
+	signup, ok := doc.Paths["/v1/signup"]["post"]
+	if !ok || signup.RequestBody == nil {
+		t.Fatalf("Expected POST /v1/signup to be documented with a request body, got %+v\n", doc.Paths["/v1/signup"])
+	}
+	if ref := signup.RequestBody.Content["application/json"].Schema.Ref; ref != "#/components/schemas/SignUpRequest" {
+		t.Errorf("Expected sign up request schema reference, got %q\n", ref)
+	}
+	if len(doc.Paths["/v1/users"]["get"].Security) == 0 {
+		t.Errorf("Expected authenticated routes to require a bearer token\n")
+	}
+	for _, name := range []string{"SignUpRequest", "User", "Problem", "Report"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("Expected schema %q in the components, got %v\n", name, doc.Components.Schemas)
+		}
+	}
+	for path, operations := range doc.Paths {
+		for method, operation := range operations {
+			if len(operation.Responses) < 2 {
+				t.Errorf("Expected %s %s to document its success and error responses, got %v\n", method, path, operation.Responses)
+			}
+		}
+	}
+	listed, _ := doc.Paths["/v1/users"]["get"].Responses["200"].(map[string]any)
+	listedContent, _ := listed["content"].(map[string]any)
+	for _, contentType := range []string{"application/json", "application/x-ndjson", "text/csv"} {
+		if _, ok := listedContent[contentType]; !ok {
+			t.Errorf("Expected the user list to be documented as %s, got %v\n", contentType, listedContent)
+		}
+	}
+	if _, ok := doc.Paths["/static/"]; ok {
+		t.Errorf("Expected internal routes to be left out of the document\n")
+	}
+}
+
+func TestAPIDocsAreServedInDevelopment(t *testing.T) {
+	// Arrange
+	handler := testRoutes(t)
+	w := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+	// Assert
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code 200, got %v\n", w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+		t.Errorf("Expected HTML content type, got %q\n", contentType)
+	}
+
+	// Act
+	script := httptest.NewRecorder()
+	handler.ServeHTTP(script, httptest.NewRequest(http.MethodGet, "/docs/docs.js", nil))
+
+	// Assert
+	if script.Code != http.StatusOK || !strings.Contains(script.Header().Get("Content-Type"), "javascript") {
+		t.Errorf("Expected the docs script to be served from the binary, got %v %q\n", script.Code, script.Header().Get("Content-Type"))
+	}
+	for _, asset := range []string{"/docs/swagger-ui/swagger-ui-bundle.js", "/docs/swagger-ui/swagger-ui.css"} {
+		assetResp := httptest.NewRecorder()
+		handler.ServeHTTP(assetResp, httptest.NewRequest(http.MethodGet, asset, nil))
+		if assetResp.Code != http.StatusOK || assetResp.Body.Len() == 0 {
+			t.Errorf("Expected Swagger UI's %s to be served from the binary, got %v\n", asset, assetResp.Code)
+		}
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); strings.Contains(csp, "https:") || !strings.Contains(csp, "default-src 'self'") {
+		t.Errorf("Expected the docs Content-Security-Policy to only allow the same origin, got %q\n", csp)
+	}
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/middleware"
+)
+
+func TestRealIPResolvesClientAddress(t *testing.T) {
+	trustedProxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+	}{
+		{"no headers", "203.0.113.7:1234", nil, "203.0.113.7"},
+		{"untrusted peer with spoofed X-Forwarded-For", "203.0.113.7:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1"}}, "203.0.113.7"},
+		{"untrusted peer with spoofed Forwarded", "203.0.113.7:1234", map[string][]string{"Forwarded": {"for=198.51.100.1"}}, "203.0.113.7"},
+		{"untrusted peer with spoofed X-Real-IP", "203.0.113.7:1234", map[string][]string{"X-Real-IP": {"198.51.100.1"}}, "203.0.113.7"},
+		{"IPv4-mapped peer", "[::ffff:203.0.113.7]:1234", nil, "203.0.113.7"},
+		{"single trusted hop", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"203.0.113.7"}}, "203.0.113.7"},
+		{"multi-hop with trusted proxies", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1, 203.0.113.7, 10.0.0.3, 10.0.0.2"}}, "203.0.113.7"},
+		{"multi-hop across repeated headers", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1, 203.0.113.7", "10.0.0.2"}}, "203.0.113.7"},
+		{"every hop trusted", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, "10.0.0.3"},
+		{"entry with port", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"203.0.113.7:4711"}}, "203.0.113.7"},
+		{"invalid entry", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"not-an-ip"}}, "10.0.0.1"},
+		{"invalid entry between hops", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"203.0.113.7, unknown, 10.0.0.2"}}, "10.0.0.1"},
+		{"invalid entry left of the client", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"unknown, 203.0.113.7"}}, "203.0.113.7"},
+		{"empty entry", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"203.0.113.7,,10.0.0.2"}}, "10.0.0.1"},
+		{"Forwarded", "10.0.0.1:1234", map[string][]string{"Forwarded": {"for=203.0.113.7;proto=https"}}, "203.0.113.7"},
+		{"Forwarded quoted with port", "10.0.0.1:1234", map[string][]string{"Forwarded": {`for="203.0.113.7:4711"`}}, "203.0.113.7"},
+		{"Forwarded quoted IPv6", "10.0.0.1:1234", map[string][]string{"Forwarded": {`for="[2001:db8::1]:4711";proto=https`}}, "2001:db8::1"},
+		{"Forwarded multi-hop", "10.0.0.1:1234", map[string][]string{"Forwarded": {`for=198.51.100.1, For="[2001:db8::1]", for="[fd00::2]"`}}, "2001:db8::1"},
+		{"Forwarded element without for", "10.0.0.1:1234", map[string][]string{"Forwarded": {"for=203.0.113.7, proto=https"}}, "10.0.0.1"},
+		{"Forwarded obfuscated identifier", "10.0.0.1:1234", map[string][]string{"Forwarded": {"for=_hidden"}}, "10.0.0.1"},
+		{"X-Real-IP", "10.0.0.1:1234", map[string][]string{"X-Real-IP": {"203.0.113.7"}}, "203.0.113.7"},
+		{"X-Real-IP IPv6", "[fd00::1]:1234", map[string][]string{"X-Real-IP": {"2001:db8::1"}}, "2001:db8::1"},
+		{"invalid X-Real-IP", "10.0.0.1:1234", map[string][]string{"X-Real-IP": {"unknown"}}, "10.0.0.1"},
+		{"X-Forwarded-For before Forwarded and X-Real-IP", "10.0.0.1:1234", map[string][]string{
+			"X-Forwarded-For": {"203.0.113.7"},
+			"Forwarded":       {"for=198.51.100.1"},
+			"X-Real-IP":       {"198.51.100.2"},
+		}, "203.0.113.7"},
+		{"Forwarded before X-Real-IP", "10.0.0.1:1234", map[string][]string{
+			"Forwarded": {"for=203.0.113.7"},
+			"X-Real-IP": {"198.51.100.2"},
+		}, "203.0.113.7"},
+		{"invalid first header doesn't fall through", "10.0.0.1:1234", map[string][]string{
+			"X-Forwarded-For": {"unknown"},
+			"X-Real-IP":       {"198.51.100.2"},
+		}, "10.0.0.1"},
+	}
+
+	for _, test := range tests {
+		// Arrange
+		var clientIP string
+		handler := middleware.RealIPMiddleware(trustedProxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientIP = middleware.ClientIP(r)
+		}))
+		r := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+		r.RemoteAddr = test.remoteAddr
+		for key, values := range test.headers {
+			for _, value := range values {
+				r.Header.Add(key, value)
+			}
+		}
+
+		// Act
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		// Assert
+		if clientIP != test.want {
+			t.Errorf("%s: expected client IP %s, got %s\n", test.name, test.want, clientIP)
+		}
+	}
+}
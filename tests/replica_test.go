@@ -0,0 +1,241 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/db"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// replicaConfig parses DATABASE_REPLICA_URL, skipping the test when no replica is configured
+func replicaConfig(t *testing.T) *pgxpool.Config {
+	t.Helper()
+	replicaURL := os.Getenv("DATABASE_REPLICA_URL")
+	if replicaURL == "" {
+		t.Skip("DATABASE_REPLICA_URL not set")
+	}
+	config, err := pgxpool.ParseConfig(replicaURL)
+	if err != nil {
+		t.Fatalf("Failed to parse DATABASE_REPLICA_URL: %v", err)
+	}
+	return config
+}
+
+func newReplicaPool(t *testing.T, config *pgxpool.Config) *pgxpool.Pool {
+	t.Helper()
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("Failed to create replica pool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+// watchRouter runs the router's replica checks every few milliseconds until the test ends
+func watchRouter(t *testing.T, router *db.Router) {
+	t.Helper()
+	watchCtx, stop := context.WithCancel(ctx)
+	t.Cleanup(stop)
+	go router.Watch(watchCtx, 10*time.Millisecond)
+}
+
+// waitForReader waits for the router to route reads to want
+func waitForReader(t *testing.T, router *db.Router, want db.Querier, description string) {
+	t.Helper()
+	// A replica's WAL receiver is restarted every wal_retrieve_retry_interval (5s by default)
+	deadline := time.Now().Add(15 * time.Second)
+	for router.Reader() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected reads to be routed to the %s\n", description)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// toggleProxy forwards connections to a database until it is taken down, which drops every open
+// connection and refuses new ones as if the database were unreachable
+type toggleProxy struct {
+	listener net.Listener
+	target   string
+	mu       sync.Mutex
+	down     bool
+	conns    []net.Conn
+}
+
+func newToggleProxy(t *testing.T, target string) *toggleProxy {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	p := &toggleProxy{listener: listener, target: target}
+	t.Cleanup(func() {
+		listener.Close()
+		p.setDown(true)
+	})
+
+	go func() {
+		for {
+			client, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			p.mu.Lock()
+			if p.down {
+				p.mu.Unlock()
+				client.Close()
+				continue
+			}
+			server, err := net.Dial("tcp", target)
+			if err != nil {
+				p.mu.Unlock()
+				client.Close()
+				continue
+			}
+			p.conns = append(p.conns, client, server)
+			p.mu.Unlock()
+			go io.Copy(server, client)
+			go io.Copy(client, server)
+		}
+	}()
+	return p
+}
+
+func (p *toggleProxy) setDown(down bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.down = down
+	if down {
+		for _, conn := range p.conns {
+			conn.Close()
+		}
+		p.conns = nil
+	}
+}
+
+func TestRouterReadsFromHealthyReplica(t *testing.T) {
+	// Arrange
+	replica := newReplicaPool(t, replicaConfig(t))
+	router := db.NewRouter(dbPool, replica, 10*time.Second)
+
+	// Act
+	beforeCheck := router.Reader()
+	watchRouter(t, router)
+
+	// Assert
+	if beforeCheck != dbPool {
+		t.Errorf("Expected reads to use the primary until the replica has been checked\n")
+	}
+	waitForReader(t, router, replica, "healthy replica")
+}
+
+func TestRouterFallsBackWhileReplicaIsUnreachable(t *testing.T) {
+	// Arrange
+	config := replicaConfig(t)
+	proxy := newToggleProxy(t, net.JoinHostPort(config.ConnConfig.Host, strconv.Itoa(int(config.ConnConfig.Port))))
+	config.ConnConfig.Host = "127.0.0.1"
+	config.ConnConfig.Port = uint16(proxy.listener.Addr().(*net.TCPAddr).Port)
+	config.ConnConfig.ConnectTimeout = time.Second
+	replica := newReplicaPool(t, config)
+	router := db.NewRouter(dbPool, replica, 10*time.Second)
+	watchRouter(t, router)
+	waitForReader(t, router, replica, "replica before it goes down")
+
+	// Act
+	proxy.setDown(true)
+
+	// Assert
+	waitForReader(t, router, dbPool, "primary while the replica is unreachable")
+
+	// Act
+	proxy.setDown(false)
+
+	// Assert
+	waitForReader(t, router, replica, "replica once it is reachable again")
+}
+
+func TestRouterFallsBackWhileReplicaLags(t *testing.T) {
+	// Arrange
+	replica := newReplicaPool(t, replicaConfig(t))
+	// Make sure the replica has replayed a transaction, so its lag is measured from a known point
+	if _, err := dbPool.Exec(ctx, "SELECT txid_current()"); err != nil {
+		t.Fatalf("Failed to write to the primary: %v", err)
+	}
+	router := db.NewRouter(dbPool, replica, 200*time.Millisecond)
+	watchRouter(t, router)
+	waitForReader(t, router, replica, "replica before it lags")
+
+	// Act
+	if _, err := replica.Exec(ctx, "SELECT pg_wal_replay_pause()"); err != nil {
+		t.Skipf("Replica replay can't be paused, which needs superuser: %v", err)
+	}
+	t.Cleanup(func() { replica.Exec(context.Background(), "SELECT pg_wal_replay_resume()") })
+	time.Sleep(300 * time.Millisecond)
+	if _, err := dbPool.Exec(ctx, "SELECT txid_current()"); err != nil {
+		t.Fatalf("Failed to write to the primary: %v", err)
+	}
+
+	// Assert
+	waitForReader(t, router, dbPool, "primary while the replica lags")
+
+	// Act
+	if _, err := replica.Exec(ctx, "SELECT pg_wal_replay_resume()"); err != nil {
+		t.Fatalf("Failed to resume replay: %v", err)
+	}
+
+	// Assert
+	waitForReader(t, router, replica, "replica once it has caught up")
+}
+
+func TestRouterFallsBackWhileReplicaReceiverIsDisconnected(t *testing.T) {
+	// Arrange
+	replica := newReplicaPool(t, replicaConfig(t))
+	if _, err := dbPool.Exec(ctx, "SELECT txid_current()"); err != nil {
+		t.Fatalf("Failed to write to the primary: %v", err)
+	}
+	router := db.NewRouter(dbPool, replica, 200*time.Millisecond)
+	watchRouter(t, router)
+	waitForReader(t, router, replica, "replica before its receiver disconnects")
+	var primaryConninfo string
+	if err := replica.QueryRow(ctx, "SHOW primary_conninfo").Scan(&primaryConninfo); err != nil {
+		t.Fatalf("Failed to read primary_conninfo: %v", err)
+	}
+	setPrimaryConninfo := func(conninfo string) error {
+		if _, err := replica.Exec(ctx, "ALTER SYSTEM SET primary_conninfo = '"+strings.ReplaceAll(conninfo, "'", "''")+"'"); err != nil {
+			return err
+		}
+		_, err := replica.Exec(ctx, "SELECT pg_reload_conf()")
+		return err
+	}
+
+	// Act
+	// Without primary_conninfo the replica stops its WAL receiver, so receive and replay stay
+	// equal while the primary moves on
+	if err := setPrimaryConninfo(""); err != nil {
+		t.Skipf("Replica primary_conninfo can't be changed, which needs superuser: %v", err)
+	}
+	t.Cleanup(func() { setPrimaryConninfo(primaryConninfo) })
+	time.Sleep(300 * time.Millisecond)
+	if _, err := dbPool.Exec(ctx, "SELECT txid_current()"); err != nil {
+		t.Fatalf("Failed to write to the primary: %v", err)
+	}
+
+	// Assert
+	waitForReader(t, router, dbPool, "primary while the replica's receiver is disconnected")
+
+	// Act
+	if err := setPrimaryConninfo(primaryConninfo); err != nil {
+		t.Fatalf("Failed to restore primary_conninfo: %v", err)
+	}
+
+	// Assert
+	waitForReader(t, router, replica, "replica once its receiver has caught up")
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anishsharma21/go-web-dev-template/internal"
+	"github.com/anishsharma21/go-web-dev-template/internal/apperr"
+	"github.com/anishsharma21/go-web-dev-template/internal/respond"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestErrorWritesProblemForDomainErrors(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+		wantType   string
+	}{
+		{fmt.Errorf("user 42: %w", apperr.ErrNotFound), http.StatusNotFound, "/problems/not-found"},
+		{fmt.Errorf("clerk_id taken: %w", apperr.ErrConflict), http.StatusConflict, "/problems/conflict"},
+		{apperr.ErrForbidden, http.StatusForbidden, "/problems/forbidden"},
+		{apperr.New(apperr.ErrConflict, "user already exists", &pgconn.PgError{Code: "23505"}), http.StatusConflict, "/problems/conflict"},
+		{errors.New("connection refused"), http.StatusInternalServerError, "/problems/internal-server-error"},
+	}
+
+	for _, test := range tests {
+		// Arrange
+		r := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+		r = r.WithContext(context.WithValue(r.Context(), internal.REQUEST_ID_KEY, "request-id"))
+		w := httptest.NewRecorder()
+
+		// Act
+		respond.Error(w, r, test.err)
+
+		// Assert
+		var problem respond.Problem
+		if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+			t.Fatalf("Failed to decode problem: %v", err)
+		}
+		if w.Code != test.wantStatus || problem.Status != test.wantStatus {
+			t.Errorf("Expected status %d for %v, got %d with body status %d\n", test.wantStatus, test.err, w.Code, problem.Status)
+		}
+		if problem.Type != test.wantType {
+			t.Errorf("Expected type %q for %v, got %q\n", test.wantType, test.err, problem.Type)
+		}
+		if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+			t.Errorf("Expected application/problem+json content type, got %q\n", contentType)
+		}
+		if problem.RequestID != "request-id" || problem.Instance != "/users/42" {
+			t.Errorf("Expected request ID and instance to be set, got %q and %q\n", problem.RequestID, problem.Instance)
+		}
+		if test.wantStatus == http.StatusInternalServerError && problem.Detail == test.err.Error() {
+			t.Errorf("Expected internal error message to be hidden, got %q\n", problem.Detail)
+		}
+		var appErr *apperr.Error
+		if errors.As(test.err, &appErr) && problem.Detail != appErr.Message {
+			t.Errorf("Expected detail %q without the database error, got %q\n", appErr.Message, problem.Detail)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/db"
+	"github.com/anishsharma21/go-web-dev-template/internal/retry"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestRetryStopsOnNonRetryableError(t *testing.T) {
+	// Arrange
+	policy := retry.DefaultPolicy()
+	policy.InitialInterval = time.Millisecond
+	policy.Retryable = db.Retryable
+	errs := []error{
+		&pgconn.PgError{Code: "57P03"}, // the database is starting up
+		errors.New("connection refused"),
+		&pgconn.PgError{Code: "28P01"}, // invalid password
+		nil,
+	}
+	attempts := 0
+
+	// Act
+	err := retry.Do(context.Background(), policy, func(ctx context.Context) error {
+		err := errs[attempts]
+		attempts++
+		return err
+	})
+
+	// Assert
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "28P01" {
+		t.Errorf("Expected invalid password error to be returned, got %v\n", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d\n", attempts)
+	}
+}
+
+func TestRetryStopsWhenContextCancelled(t *testing.T) {
+	// Arrange
+	policy := retry.DefaultPolicy()
+	policy.InitialInterval = time.Hour
+	policy.MaxElapsed = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+
+	// Act
+	err := retry.Do(ctx, policy, func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+
+	// Assert
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context deadline error, got %v\n", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected retry to stop when the context was cancelled, took %s\n", elapsed)
+	}
+}
+
+func TestRetryRetriesConnectTimeouts(t *testing.T) {
+	// Arrange
+	// The listener accepts connections but never answers the startup message, like a database
+	// which is still starting
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	connConfig, err := pgconn.ParseConfig("postgres://user@" + listener.Addr().String() + "/db?sslmode=disable")
+	if err != nil {
+		t.Fatalf("Failed to parse connection string: %v", err)
+	}
+	connConfig.ConnectTimeout = 20 * time.Millisecond
+
+	policy := retry.DefaultPolicy()
+	policy.InitialInterval = time.Millisecond
+	policy.MaxAttempts = 3
+	policy.Retryable = db.Retryable
+	attempts := 0
+
+	// Act
+	err = retry.Do(context.Background(), policy, func(ctx context.Context) error {
+		attempts++
+		conn, err := pgconn.ConnectConfig(ctx, connConfig)
+		if err == nil {
+			conn.Close(ctx)
+		}
+		return err
+	})
+
+	// Assert
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a connect timeout error, got %v\n", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected connect timeouts to be retried until MaxAttempts, got %d attempts\n", attempts)
+	}
+}
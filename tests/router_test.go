@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/router"
+)
+
+func TestRouterGroupsApplyMiddlewareInOrder(t *testing.T) {
+	// Arrange
+	var calls []string
+	record := func(name string) router.Middleware {
+		return router.Named(name, func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		})
+	}
+
+	r := router.New()
+	v1 := r.Group("/v1", record("logging"))
+	admin := v1.Group("/admin", record("auth"))
+	admin.Handle("admin.users.get", http.MethodGet, "/users/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler:"+r.PathValue("id"))
+	}), record("timeout"))
+
+	// Act
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/admin/users/42", nil))
+	path, err := r.Path("admin.users.get", "user 7")
+
+	// Assert
+	if strings.Join(calls, ",") != "logging,auth,timeout,handler:42" {
+		t.Errorf("Expected group middleware to run before route middleware, got %v\n", calls)
+	}
+	if err != nil || path != "/v1/admin/users/user%207" {
+		t.Errorf("Expected path /v1/admin/users/user%%207, got %q and %v\n", path, err)
+	}
+	routes := r.Routes()
+	if len(routes) != 1 || routes[0].Pattern != "GET /v1/admin/users/{id}" {
+		t.Fatalf("Expected the route to be listed with its full pattern, got %+v\n", routes)
+	}
+	if strings.Join(routes[0].Middleware, ",") != "logging,auth,timeout" {
+		t.Errorf("Expected the route's middleware to be listed outermost first, got %v\n", routes[0].Middleware)
+	}
+}
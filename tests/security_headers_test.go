@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/config"
+	"github.com/anishsharma21/go-web-dev-template/internal/middleware"
+)
+
+func TestHTTPSRedirect(t *testing.T) {
+	trustedProxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	tests := []struct {
+		name         string
+		path         string
+		remoteAddr   string
+		forwardProto string
+		wantRedirect bool
+	}{
+		{"plain HTTP", "/v1/users?page=2", "203.0.113.7:1234", "", true},
+		{"exempt path", "/healthz", "203.0.113.7:1234", "", false},
+		{"HTTPS from trusted proxy", "/v1/users", "10.0.0.2:1234", "https", false},
+		{"HTTPS list from trusted proxies", "/v1/users", "10.0.0.2:1234", "https, http", false},
+		{"HTTP from trusted proxy", "/v1/users", "10.0.0.2:1234", "http", true},
+		{"HTTPS spoofed by client", "/v1/users", "203.0.113.7:1234", "https", true},
+	}
+
+	for _, test := range tests {
+		// Arrange
+		var reached bool
+		handler := middleware.HTTPSRedirectMiddleware(trustedProxies, "/healthz")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reached = true
+		}))
+		r := httptest.NewRequest(http.MethodGet, "http://api.example.com"+test.path, nil)
+		r.RemoteAddr = test.remoteAddr
+		if test.forwardProto != "" {
+			r.Header.Set("X-Forwarded-Proto", test.forwardProto)
+		}
+		w := httptest.NewRecorder()
+
+		// Act
+		handler.ServeHTTP(w, r)
+
+		// Assert
+		if !test.wantRedirect {
+			if !reached || w.Code != http.StatusOK {
+				t.Errorf("%s: expected the request to reach the handler, got status %d\n", test.name, w.Code)
+			}
+			continue
+		}
+		if reached {
+			t.Errorf("%s: expected the request not to reach the handler\n", test.name)
+		}
+		if w.Code != http.StatusPermanentRedirect {
+			t.Errorf("%s: expected status %d, got %d\n", test.name, http.StatusPermanentRedirect, w.Code)
+		}
+		if location := w.Header().Get("Location"); location != "https://api.example.com"+test.path {
+			t.Errorf("%s: expected redirect to the HTTPS URL, got %q\n", test.name, location)
+		}
+	}
+}
+
+func TestHSTSIsOnlySentInProduction(t *testing.T) {
+	tests := []struct {
+		environment string
+		wantHSTS    bool
+	}{
+		{config.Development, false},
+		{config.Test, false},
+		{config.Production, true},
+	}
+
+	for _, test := range tests {
+		// Arrange
+		handler := testRoutesFor(t, config.Defaults(test.environment))
+		w := httptest.NewRecorder()
+
+		// Act
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		// Assert
+		hsts := w.Header().Get("Strict-Transport-Security")
+		if test.wantHSTS != (hsts != "") {
+			t.Errorf("Expected HSTS %t in %s, got %q\n", test.wantHSTS, test.environment, hsts)
+		}
+		if test.wantHSTS && !strings.HasPrefix(hsts, "max-age=31536000") {
+			t.Errorf("Expected a one year HSTS max age in %s, got %q\n", test.environment, hsts)
+		}
+		if w.Header().Get("X-Content-Type-Options") != "nosniff" || w.Header().Get("X-Frame-Options") != "DENY" {
+			t.Errorf("Expected default security headers in %s, got %v\n", test.environment, w.Header())
+		}
+	}
+}
+
+func TestSecurityHeadersCanBeOverriddenPerRoute(t *testing.T) {
+	// Arrange
+	defaults := middleware.SecurityHeadersOptions{
+		HSTSMaxAge:            time.Hour,
+		HSTSIncludeSubdomains: true,
+		HSTSPreload:           true,
+		ContentSecurityPolicy: "default-src 'none'",
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "no-referrer",
+		ContentTypeNosniff:    true,
+	}
+	override := defaults
+	override.ContentSecurityPolicy = "default-src 'self'"
+	override.FrameOptions = ""
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := middleware.SecurityHeadersMiddleware(defaults)(middleware.SecurityHeadersMiddleware(override)(inner))
+	w := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+	// Assert
+	header := w.Header()
+	if csp := header.Get("Content-Security-Policy"); csp != "default-src 'self'" {
+		t.Errorf("Expected the route's Content-Security-Policy, got %q\n", csp)
+	}
+	if _, ok := header["X-Frame-Options"]; ok {
+		t.Errorf("Expected an empty override to remove X-Frame-Options, got %q\n", header.Get("X-Frame-Options"))
+	}
+	if hsts := header.Get("Strict-Transport-Security"); hsts != "max-age=3600; includeSubDomains; preload" {
+		t.Errorf("Expected HSTS to be kept, got %q\n", hsts)
+	}
+	if header.Get("Referrer-Policy") != "no-referrer" || header.Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("Expected the other default headers to be kept, got %v\n", header)
+	}
+}
@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"iter"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/middleware"
+	"github.com/anishsharma21/go-web-dev-template/internal/respond"
+)
+
+type exportRow struct {
+	ID        int       `json:"id"`
+	Name      *string   `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	internal  string
+}
+
+func exportRows(err error) iter.Seq2[exportRow, error] {
+	name := "Ada, \"the first\""
+	createdAt := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	rows := []exportRow{{ID: 1, Name: &name, CreatedAt: createdAt}, {ID: 2, CreatedAt: createdAt}}
+
+	return func(yield func(exportRow, error) bool) {
+		if err != nil {
+			yield(exportRow{}, err)
+			return
+		}
+		for _, row := range rows {
+			if !yield(row, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestNegotiatePrefersFormatParameterThenAccept(t *testing.T) {
+	tests := []struct {
+		target     string
+		accept     string
+		wantFormat respond.Format
+		wantStatus int
+	}{
+		{"/users", "", respond.JSON, 0},
+		{"/users", "text/csv", respond.CSV, 0},
+		{"/users", "application/json;q=0.5, application/x-ndjson", respond.NDJSON, 0},
+		{"/users", "text/*", respond.CSV, 0},
+		{"/users?format=ndjson", "text/csv", respond.NDJSON, 0},
+		{"/users?format=xml", "", respond.Format{}, http.StatusBadRequest},
+		{"/users", "application/xml", respond.Format{}, http.StatusNotAcceptable},
+	}
+
+	for _, test := range tests {
+		// Arrange
+		r := httptest.NewRequest(http.MethodGet, test.target, nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+
+		// Act
+		format, err := respond.Negotiate(r, respond.JSON, respond.NDJSON, respond.CSV)
+
+		// Assert
+		var problem *respond.Problem
+		if test.wantStatus != 0 {
+			if !errors.As(err, &problem) || problem.Status != test.wantStatus {
+				t.Errorf("Expected %d problem for %s with Accept %q, got %v\n", test.wantStatus, test.target, test.accept, err)
+			}
+			continue
+		}
+		if err != nil || format != test.wantFormat {
+			t.Errorf("Expected %s for %s with Accept %q, got %s and %v\n", test.wantFormat.Name, test.target, test.accept, format.Name, err)
+		}
+	}
+}
+
+func TestStreamListEncodesEachFormat(t *testing.T) {
+	tests := []struct {
+		format   respond.Format
+		wantBody string
+	}{
+		{respond.JSON, `[{"id":1,"name":"Ada, \"the first\"","created_at":"2026-10-19T12:00:00Z"},{"id":2,"name":null,"created_at":"2026-10-19T12:00:00Z"}]` + "\n"},
+		{respond.NDJSON, `{"id":1,"name":"Ada, \"the first\"","created_at":"2026-10-19T12:00:00Z"}` + "\n" + `{"id":2,"name":null,"created_at":"2026-10-19T12:00:00Z"}` + "\n"},
+		{respond.CSV, "id,name,created_at\n1,\"Ada, \"\"the first\"\"\",2026-10-19T12:00:00Z\n2,,2026-10-19T12:00:00Z\n"},
+	}
+
+	for _, test := range tests {
+		// Arrange
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/users", nil)
+
+		// Act
+		respond.StreamList(w, r, test.format, exportRows(nil))
+
+		// Assert
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code 200 for %s, got %v\n", test.format.Name, w.Code)
+		}
+		if body := w.Body.String(); body != test.wantBody {
+			t.Errorf("Expected %s body %q, got %q\n", test.format.Name, test.wantBody, body)
+		}
+	}
+}
+
+func TestStreamListWritesProblemForErrorBeforeFirstItem(t *testing.T) {
+	// Arrange
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/users?format=csv", nil)
+
+	// Act
+	respond.StreamList(w, r, respond.CSV, exportRows(errors.New("connection reset")))
+
+	// Assert
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code 500, got %v\n", w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+		t.Errorf("Expected problem content type, got %q\n", contentType)
+	}
+}
+
+func TestStreamListAbortsCompressedResponseOnError(t *testing.T) {
+	// Arrange
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	defer slog.SetDefault(defaultLogger)
+	rows := func(yield func(exportRow, error) bool) {
+		if yield(exportRow{ID: 1}, nil) {
+			yield(exportRow{}, errors.New("connection reset"))
+		}
+	}
+	handler := middleware.LoggingMiddleware(middleware.CompressionMiddleware(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond.StreamList(w, r, respond.NDJSON, rows)
+	})))
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/users?format=ndjson", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	// Act
+	var recovered any
+	func() {
+		defer func() { recovered = recover() }()
+		handler.ServeHTTP(w, r)
+	}()
+
+	// Assert
+	if recovered != http.ErrAbortHandler {
+		t.Fatalf("Expected the response to be aborted, got %v\n", recovered)
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Expected the rows before the error to be compressed, got %v\n", err)
+	}
+	if _, err := io.ReadAll(gz); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected the compressed stream to be left unfinished, got %v\n", err)
+	}
+	for _, want := range []string{`"msg":"Failed to stream list, aborting response"`, `"msg":"GET /users 200","method":"GET"`, `"aborted":true`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("Expected the logs to contain %s, got %s\n", want, logs.String())
+		}
+	}
+}
@@ -4,15 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
 	"log"
+	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
-	"github.com/anishsharma21/go-web-dev-template/internal"
+	"github.com/anishsharma21/go-web-dev-template/internal/db"
 	"github.com/anishsharma21/go-web-dev-template/internal/handlers"
+	"github.com/anishsharma21/go-web-dev-template/internal/retry"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -20,26 +22,30 @@ var dbPool *pgxpool.Pool
 var ctx, cancel = context.WithCancel(context.Background())
 
 func TestMain(m *testing.M) {
-	dbConnStr := os.Getenv(internal.DATABASE_URL)
+	dbConnStr := os.Getenv("DATABASE_URL")
 	config, err := pgxpool.ParseConfig(dbConnStr)
 	if err != nil {
 		log.Fatalf("Failed to parse database connection string.\n")
 	}
 
-	for i := 1; i <= 5; i++ {
-		dbPool, err = pgxpool.NewWithConfig(ctx, config)
-		if err == nil && dbPool != nil {
-			break
-		}
-		log.Printf("Failed to initialise database connection pool")
-		log.Printf(fmt.Sprintf("Retrying in %d seconds...", i*i))
-		time.Sleep(time.Duration(i*i) * time.Second)
-	}
-	if dbPool == nil {
-		log.Fatalf("Failed to initialise database connection pool after 5 attempts")
+	dbPool, err = pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		log.Fatalf("Failed to initialise database connection pool: %v", err)
 	}
 	defer dbPool.Close()
 
+	// Wait for the database in case it is still starting, giving up early on errors such as bad credentials
+	policy := retry.DefaultPolicy()
+	policy.MaxElapsed = 30 * time.Second
+	policy.Retryable = db.Retryable
+	policy.OnRetry = func(attempt int, err error, wait time.Duration) {
+		log.Printf("Failed to ping database, retrying in %s: %v", wait.Round(time.Millisecond), err)
+	}
+	if err := retry.Do(ctx, policy, dbPool.Ping); err != nil {
+		// Tests which don't need the database still run, the others fail with the connection error
+		log.Printf("Database unavailable: %v", err)
+	}
+
 	// Run the tests
 	code := m.Run()
 
@@ -81,3 +87,96 @@ func TestUserSignUpFlow(t *testing.T) {
 		t.Fatalf("Failed to delete user from database, %v\n", err)
 	}
 }
+
+func TestUserHandlersTranslateDatabaseErrors(t *testing.T) {
+	// Arrange
+	mux := http.NewServeMux()
+	mux.Handle("POST /signup", handlers.AddNewUser(dbPool))
+	mux.Handle("DELETE /users/{id}", handlers.DeleteUserByID(dbPool))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	clerkID := "testclerkid_duplicate"
+	signUp := func() *http.Response {
+		resp, err := ts.Client().Post(ts.URL+"/signup", "application/json", strings.NewReader(`{"clerk_id":"`+clerkID+`"}`))
+		if err != nil {
+			t.Fatalf("Expected no error when sending POST request, got %v\n", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	deleteUser := func(id string) *http.Response {
+		req, err := http.NewRequest(http.MethodDelete, ts.URL+"/users/"+id, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error when sending DELETE request, got %v\n", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	defer dbPool.Exec(ctx, "DELETE FROM users WHERE clerk_id = $1", clerkID)
+
+	// Act
+	first := signUp()
+	duplicate := signUp()
+	missing := deleteUser("2147483000")
+	invalid := deleteUser("not-a-number")
+
+	// Assert
+	if first.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status code 201, got %v\n", first.StatusCode)
+	}
+	if duplicate.StatusCode != http.StatusConflict {
+		t.Errorf("Expected status code 409 for a duplicate sign up, got %v\n", duplicate.StatusCode)
+	}
+	if missing.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code 404 for a missing user, got %v\n", missing.StatusCode)
+	}
+	if invalid.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code 400 for a non-numeric id, got %v\n", invalid.StatusCode)
+	}
+}
+
+func TestGetUsersReturnsEveryUserUnlessAPageIsRequested(t *testing.T) {
+	// Arrange
+	ts := httptest.NewServer(handlers.GetUsers(db.NewRouter(dbPool, nil, 0), 10*time.Second, time.Minute))
+	defer ts.Close()
+	var total int
+	if err := dbPool.QueryRow(ctx, "SELECT count(*) FROM users").Scan(&total); err != nil {
+		t.Fatalf("Failed to count users: %v", err)
+	}
+	clerkIDs := []string{"testclerkid_list_1", "testclerkid_list_2"}
+	for _, clerkID := range clerkIDs {
+		if _, err := dbPool.Exec(ctx, "INSERT INTO users (clerk_id) VALUES ($1)", clerkID); err != nil {
+			t.Fatalf("Failed to insert user: %v", err)
+		}
+	}
+	defer dbPool.Exec(ctx, "DELETE FROM users WHERE clerk_id = ANY($1)", clerkIDs)
+	list := func(query string) ([]map[string]any, *http.Response) {
+		resp, err := ts.Client().Get(ts.URL + query)
+		if err != nil {
+			t.Fatalf("Expected no error when sending GET request, got %v\n", err)
+		}
+		defer resp.Body.Close()
+		var users []map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&users); err != nil {
+			t.Fatalf("Failed to decode users: %v", err)
+		}
+		return users, resp
+	}
+
+	// Act
+	all, allResp := list("")
+	page, pageResp := list("?limit=1")
+
+	// Assert
+	if len(all) != total+len(clerkIDs) || allResp.Header.Get("Link") != "" {
+		t.Errorf("Expected all %d users without a next link, got %d and Link %q\n", total+len(clerkIDs), len(all), allResp.Header.Get("Link"))
+	}
+	if len(page) != 1 || !strings.Contains(pageResp.Header.Get("Link"), `rel="next"`) {
+		t.Errorf("Expected one user and a next link when a page is requested, got %d and Link %q\n", len(page), pageResp.Header.Get("Link"))
+	}
+}
@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/handlers"
+	"github.com/anishsharma21/go-web-dev-template/internal/respond"
+)
+
+func TestAddNewUserRejectsInvalidBodies(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantFields []string
+	}{
+		{"unknown field", `{"clerk_id": "user_123", "id": 7}`, http.StatusBadRequest, []string{"id"}},
+		{"wrong type", `{"clerk_id": 123}`, http.StatusBadRequest, []string{"clerk_id"}},
+		{"invalid fields", `{"clerk_id": "", "email": "not-an-email"}`, http.StatusUnprocessableEntity, []string{"clerk_id", "email"}},
+		{"trailing data", `{"clerk_id": "user_123"} {}`, http.StatusBadRequest, nil},
+		{"empty body", ``, http.StatusBadRequest, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			r := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(test.body))
+			w := httptest.NewRecorder()
+
+			// Act
+			handlers.AddNewUser(dbPool).ServeHTTP(w, r)
+
+			// Assert
+			if w.Code != test.wantStatus {
+				t.Errorf("Expected status code %d, got %v\n", test.wantStatus, w.Code)
+			}
+			var problem respond.Problem
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Fatalf("Failed to decode problem: %v", err)
+			}
+			var fields []string
+			for _, fieldErr := range problem.Errors {
+				fields = append(fields, fieldErr.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(test.wantFields, ",") {
+				t.Errorf("Expected errors for fields %v, got %v\n", test.wantFields, problem.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/anishsharma21/go-web-dev-template/internal/handlers"
+	"github.com/anishsharma21/go-web-dev-template/internal/secrets"
+	svix "github.com/svix/svix-webhooks/go"
+)
+
+func TestWebhookVerifierAcceptsAnyActiveSecret(t *testing.T) {
+	// Arrange
+	oldSecret := "whsec_" + base64.StdEncoding.EncodeToString([]byte("old-signing-secret-for-tests"))
+	newSecret := "whsec_" + base64.StdEncoding.EncodeToString([]byte("new-signing-secret-for-tests"))
+	signingSecrets := secrets.NewValue("CLERK_WEBHOOK_SIGNING_SECRET", oldSecret+","+newSecret, nil)
+
+	verifier, err := handlers.NewWebhookVerifier(signingSecrets)
+	if err != nil {
+		t.Fatalf("Failed to create webhook verifier: %v", err)
+	}
+
+	body := []byte(`{"type":"user.created","data":{}}`)
+	sign := func(secret string) http.Header {
+		wh, err := svix.NewWebhook(secret)
+		if err != nil {
+			t.Fatalf("Failed to create webhook signer: %v", err)
+		}
+		now := time.Now()
+		signature, err := wh.Sign("msg_test", now, body)
+		if err != nil {
+			t.Fatalf("Failed to sign payload: %v", err)
+		}
+		headers := http.Header{}
+		headers.Set("svix-id", "msg_test")
+		headers.Set("svix-timestamp", strconv.FormatInt(now.Unix(), 10))
+		headers.Set("svix-signature", signature)
+		return headers
+	}
+
+	// Act
+	oldFingerprint, oldErr := verifier.Verify(body, sign(oldSecret))
+	newFingerprint, newErr := verifier.Verify(body, sign(newSecret))
+	_, unknownErr := verifier.Verify(body, sign("whsec_"+base64.StdEncoding.EncodeToString([]byte("unknown"))))
+
+	// Assert
+	if oldErr != nil || newErr != nil {
+		t.Fatalf("Expected both active secrets to verify, got %v and %v\n", oldErr, newErr)
+	}
+	if oldFingerprint == newFingerprint {
+		t.Errorf("Expected different fingerprints for different secrets, got %q for both\n", oldFingerprint)
+	}
+	if unknownErr == nil {
+		t.Errorf("Expected signature from an unknown secret to be rejected\n")
+	}
+}